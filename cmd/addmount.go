@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// addMountOptions holds the settings used to add the tools mount to the target containers.
+type addMountOptions struct {
+	debugImage string
+	ociRuntime string
+	// followSymlinks resolves the tools' symlinks and stages the libraries they need.
+	followSymlinks bool
+	// copyLibs copies the staged libraries the targets lack into their root filesystem, where they stay.
+	copyLibs      bool
+	addMountImage string
+	// noPullHelper uses the local addmount image instead of pulling it.
+	noPullHelper bool
+	// usernsMode is the user namespace mode of the addmount container, host to opt out of the daemon's remapping.
+	usernsMode container.UsernsMode
+	// pullTimeout bounds the pull of the addmount image.
+	pullTimeout time.Duration
+	// logLimits bounds the toolkit and addmount logs printed when adding the mount fails.
+	logLimits logLimits
+	// keepContainers keeps the toolkit and addmount containers for troubleshooting debug-ctr itself.
+	keepContainers bool
+}
+
+// addMountSession is a running toolkit container whose tools can be mounted into any number of target containers,
+// so that the toolkit and the addmount image are only set up once per invocation.
+type addMountSession struct {
+	opts             addMountOptions
+	socket           string
+	toolkitContainer string
+	// toolsDir is the directory of the toolkit container mounted at /bin in the targets.
+	toolsDir string
+	// libs are the libraries staged with --follow-symlinks.
+	libs []string
+}
+
+// newAddMountSession runs the toolkit container and makes the addmount image available.
+// The caller must call close to remove the toolkit container.
+func newAddMountSession(ctx context.Context, opts addMountOptions) (*addMountSession, error) {
+	socket, err := daemonSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// Run toolkit image
+	toolkitContainerResp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      opts.debugImage,
+		Entrypoint: []string{"/bin/sh", "-c", "tail -f /dev/null"}, // keep container running in the background
+	}, nil, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	s := &addMountSession{
+		opts:             opts,
+		socket:           socket,
+		toolkitContainer: toolkitContainerResp.ID,
+		toolsDir:         "/bin",
+	}
+	slog.Debug("Starting toolkit container", "container", s.toolkitContainer, "image", opts.debugImage)
+	if err := cli.ContainerStart(ctx, s.toolkitContainer, types.ContainerStartOptions{}); err != nil {
+		s.close()
+		if isExecNotFound(err) {
+			return nil, fmt.Errorf("the debug image %s must include /bin/sh to run the toolkit container: %w", opts.debugImage, err)
+		}
+		return nil, err
+	}
+
+	if opts.followSymlinks {
+		s.toolsDir, s.libs, err = stageResolvedTools(ctx, s.toolkitContainer, s.toolsDir)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+
+	if err := s.verifyTools(ctx); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// The addmount container is privileged, which the daemon refuses in a remapped user namespace
+	if remapped, err := isUsernsRemapped(ctx); err == nil && remapped && !opts.usernsMode.IsHost() {
+		slog.Warn("the Docker daemon remaps the users of the containers, adding the mount is likely to fail without --userns=host")
+	}
+
+	if err := s.ensureAddMountImage(ctx); err != nil {
+		s.close()
+		return nil, withExitCode(exitCodePullFailed, err)
+	}
+	return s, nil
+}
+
+// verifyTools checks the toolkit container is still running and has tools to mount, so a broken debug image fails
+// here instead of silently mounting an empty directory into the targets.
+func (s *addMountSession) verifyTools(ctx context.Context) error {
+	inspect, err := cli.ContainerInspect(ctx, s.toolkitContainer)
+	if err != nil {
+		return err
+	}
+	if !inspect.State.Running {
+		if err := printContainerLogs(ctx, s.toolkitContainer, "toolkit", s.opts.logLimits); err != nil {
+			slog.Warn("could not get toolkit container logs", "error", err)
+		}
+		return fmt.Errorf("the toolkit container of %s exited with status %d, the image must keep /bin/sh running", s.opts.debugImage, inspect.State.ExitCode)
+	}
+
+	out, err := execOutput(ctx, s.toolkitContainer, []string{"/bin/sh", "-c", `ls -A "$1"`, "sh", s.toolsDir})
+	if err != nil {
+		return fmt.Errorf("listing the tools of %s: %w", s.opts.debugImage, err)
+	}
+	if strings.TrimSpace(out) == "" {
+		return fmt.Errorf("the debug image %s has no tools in %s", s.opts.debugImage, s.toolsDir)
+	}
+	return nil
+}
+
+// ensureAddMountImage pulls the addmount image, unless --no-pull-helper asks to reuse the local one.
+func (s *addMountSession) ensureAddMountImage(ctx context.Context) error {
+	if !s.opts.noPullHelper {
+		ctx, cancel := context.WithTimeout(ctx, s.opts.pullTimeout)
+		defer cancel()
+		err := pullImage(ctx, s.opts.addMountImage)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("pulling %s took longer than --pull-timeout=%s: %w", s.opts.addMountImage, s.opts.pullTimeout, err)
+		}
+		return err
+	}
+	if _, _, err := cli.ImageInspectWithRaw(ctx, s.opts.addMountImage); err != nil {
+		return fmt.Errorf("addmount image %s is not available locally, pull it or remove --no-pull-helper: %w", s.opts.addMountImage, err)
+	}
+	return nil
+}
+
+// mount mounts the tools from the toolkit container (e.g. `busybox`) into the target container **without** having to restart it.
+// The benefit of this approach is that you wouldn't lose the running state of the container and the tools are available in the target container.
+func (s *addMountSession) mount(ctx context.Context, targetContainer string) error {
+	if len(s.libs) > 0 {
+		if err := copyMissingLibs(ctx, s.toolkitContainer, targetContainer, s.libs, s.opts.copyLibs); err != nil {
+			return err
+		}
+	}
+
+	// Add mount to the original container
+	addMountContainerResp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image: s.opts.addMountImage,
+		Cmd:   []string{s.toolkitContainer, s.toolsDir, targetContainer, "/bin"},
+	}, &container.HostConfig{
+		// The container is removed below, once its logs have been collected in case it failed
+		Privileged: true,
+		Runtime:    s.opts.ociRuntime,
+		PidMode:    "host",
+		UsernsMode: s.opts.usernsMode,
+		Binds: []string{
+			s.socket + ":" + defaultDockerSocket,
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerStart(ctx, addMountContainerResp.ID, types.ContainerStartOptions{}); err != nil {
+		if s.opts.keepContainers {
+			printKeptContainer("addmount", addMountContainerResp.ID)
+		} else {
+			removeDebugContainer(addMountContainerResp.ID)
+		}
+		return err
+	}
+	var exitCode int64
+	statusCh, errCh := cli.ContainerWait(ctx, addMountContainerResp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if !s.opts.keepContainers {
+			removeDebugContainer(addMountContainerResp.ID)
+		}
+		return fmt.Errorf("waiting for the addmount container: %w", err)
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+		slog.Debug("addmount container exited", "status", exitCode)
+		if exitCode != 0 {
+			if err := printContainerLogs(ctx, s.toolkitContainer, "toolkit", s.opts.logLimits); err != nil {
+				slog.Warn("could not get toolkit container logs", "error", err)
+			}
+			if err := printContainerLogs(ctx, addMountContainerResp.ID, "addmount", s.opts.logLimits); err != nil {
+				slog.Warn("could not get addmount container logs", "error", err)
+			}
+		}
+	}
+
+	// Remove the addmount container
+	if s.opts.keepContainers {
+		printKeptContainer("addmount", addMountContainerResp.ID)
+	} else if err := cli.ContainerRemove(ctx, addMountContainerResp.ID, types.ContainerRemoveOptions{
+		Force: true,
+	}); err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("adding the mount to container %s failed: addmount container exited with status %d", targetContainer, exitCode)
+	}
+	// addmount exits successfully without effect if it didn't reach the target's namespaces through the host's PID
+	// namespace. The target may have its own /bin/sh, only a mount on /bin proves the tools were added
+	if !hasToolsMount(ctx, targetContainer) {
+		return fmt.Errorf("/bin of %s is not a mount point after adding the mount, the addmount container may not have access to the host's PID namespace (e.g. in a VM or under a security policy), use the copy command instead", targetContainer)
+	}
+	emitEvent(event{Type: eventMountAdded, Container: targetContainer, Image: s.opts.debugImage})
+	return nil
+}
+
+// addMountExecCommand returns the docker exec command opening a shell of the tools added to the /bin of container.
+func addMountExecCommand(container string) string {
+	return fmt.Sprintf("docker exec -it %s /bin/sh", shellQuote(container))
+}
+
+// toolsMountScript exits successfully if /bin is a mount point, i.e. the tools were already mounted into the container.
+// It only uses shell builtins so it runs with the mounted busybox as well as the target's own shell.
+const toolsMountScript = `while read -r _ _ _ _ mnt _; do [ "$mnt" = /bin ] && exit 0; done < /proc/self/mountinfo; exit 1`
+
+// hasToolsMount reports whether the tools were already mounted into targetContainer by a previous run, so that they
+// aren't mounted again on top of each other.
+func hasToolsMount(ctx context.Context, targetContainer string) bool {
+	// Without a shell in the target the exec fails, which means the tools aren't there either
+	_, err := execOutput(ctx, targetContainer, addMountExecArgs(toolsMountScript))
+	return err == nil
+}
+
+// close removes the toolkit container.
+func (s *addMountSession) close() {
+	if s.opts.keepContainers {
+		printKeptContainer("toolkit", s.toolkitContainer)
+		return
+	}
+	if err := cli.ContainerRemove(context.Background(), s.toolkitContainer, types.ContainerRemoveOptions{
+		Force: true,
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("could not remove toolkit container %s", s.toolkitContainer), "error", err)
+	}
+}
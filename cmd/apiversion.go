@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/versions"
+)
+
+// apiFeatures are the features of debug-ctr needing a Docker API more recent than the oldest one the client can
+// negotiate, with the API version introducing them. Older daemons ignore the fields they don't know or reject the
+// requests in obscure ways.
+var apiFeatures = []struct {
+	version string
+	feature string
+}{
+	{"1.25", "running an init process in the copy container (--init)"},
+	{"1.30", "waiting for the containers populating the tools and adding the mount to exit"},
+	{"1.32", "pulling the images of another platform (--fallback-platforms)"},
+	{"1.40", "adding GPUs to the copy container (--gpus)"},
+}
+
+// unsupportedAPIFeatures returns the features of apiFeatures that the API version doesn't support.
+func unsupportedAPIFeatures(version string) []string {
+	var unsupported []string
+	for _, f := range apiFeatures {
+		if versions.LessThan(version, f.version) {
+			unsupported = append(unsupported, fmt.Sprintf("%s needs API %s", f.feature, f.version))
+		}
+	}
+	return unsupported
+}
+
+// apiVersionTimeout bounds the ping negotiating the API version, so a hung daemon doesn't block every command before
+// it starts.
+const apiVersionTimeout = 3 * time.Second
+
+// warnOldAPIVersion negotiates the API version with the daemon and warns about the features it doesn't support. An
+// unreachable or slow daemon is left to the command to report, the version isn't checked then.
+func warnOldAPIVersion(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, apiVersionTimeout)
+	defer cancel()
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		return
+	}
+	cli.NegotiateAPIVersionPing(ping)
+	if unsupported := unsupportedAPIFeatures(cli.ClientVersion()); len(unsupported) > 0 {
+		slog.Warn(fmt.Sprintf("the Docker daemon only supports API version %s, upgrade it as some features won't work: %s", cli.ClientVersion(), strings.Join(unsupported, "; ")))
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Labels set by Docker Compose on the containers of a service.
+const (
+	composeProjectLabel         = "com.docker.compose.project"
+	composeServiceLabel         = "com.docker.compose.service"
+	composeContainerNumberLabel = "com.docker.compose.container-number"
+)
+
+// resolveComposeService returns the name of the container of a Docker Compose service. project can be empty if the
+// service name is unique across projects, index selects a replica by its container number and is required if the
+// service has several replicas.
+func resolveComposeService(ctx context.Context, project, service string, index int) (string, error) {
+	args := filters.NewArgs(filters.Arg("label", composeServiceLabel+"="+service))
+	if project != "" {
+		args.Add("label", composeProjectLabel+"="+project)
+	}
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("no container found for Compose service %s", service))
+	}
+
+	projects := map[string]bool{}
+	for _, c := range containers {
+		projects[c.Labels[composeProjectLabel]] = true
+	}
+	if len(projects) > 1 {
+		return "", fmt.Errorf("Compose service %s exists in several projects, select one with --compose-project", service)
+	}
+
+	var names, replicas []string
+	for _, c := range containers {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		number := c.Labels[composeContainerNumberLabel]
+		if index > 0 && number == strconv.Itoa(index) {
+			return name, nil
+		}
+		names = append(names, name)
+		replicas = append(replicas, fmt.Sprintf("%s (index %s)", name, number))
+	}
+	if index > 0 {
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("Compose service %s has no replica with index %d, it has %s", service, index, strings.Join(replicas, ", ")))
+	}
+	if len(containers) > 1 {
+		return "", fmt.Errorf("Compose service %s has several replicas, select one with --compose-index: %s", service, strings.Join(replicas, ", "))
+	}
+	return names[0], nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/moby/term"
+)
+
+// confirm asks the user to confirm the destructive action described by action, unless yes is set. Without a terminal
+// there is no one to answer: the action goes on if it's allowed unattended, as scripts always did, otherwise it must
+// be confirmed with --yes.
+func confirm(action string, yes, unattended bool) error {
+	if yes {
+		return nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		if unattended {
+			return nil
+		}
+		return fmt.Errorf("%s, confirm with --yes", action)
+	}
+	return askConfirmation(action, os.Stdin, os.Stderr)
+}
+
+// askConfirmation prints action to out and fails unless the answer read from in is yes.
+func askConfirmation(action string, in io.Reader, out io.Writer) error {
+	fmt.Fprintf(out, "%s, continue? [y/N]: ", action)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("aborted, nothing was changed")
+}
@@ -0,0 +1,1099 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Debug a copy of a container using a image",
+	Long: `Create a "copy" of a container with the tools of an image mounted at ` + debuggerMountPath + ` and its configuration
+changed to aid debugging, e.g. when the target has crashed or doesn't include a shell.`,
+	Example: `
+debug-ctr copy --image=docker.io/alpine:latest --target=my-distroless --to=my-distroless-copy
+debug-ctr copy --image=docker.io/alpine:latest --target=my-distroless --to=my-distroless-copy --entrypoint="/.debugger/sleep" --cmd="365d"
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var targets []string
+		if target, _ := cmd.PersistentFlags().GetString("target"); target != "" {
+			targets = append(targets, target)
+		}
+		copyContainerName, _ := cmd.PersistentFlags().GetString("to")
+		return runDebug(cmd, targets, copyContainerName)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+
+	addSessionFlags(copyCmd.PersistentFlags())
+	copyCmd.PersistentFlags().String("target", "", "(required unless --compose-service or --service is specified, otherwise picked from a list on a terminal) The target container to copy")
+	copyCmd.PersistentFlags().String("to", "", "(required) The name of the copy container")
+	addCopyFlags(copyCmd.PersistentFlags(), "")
+
+	_ = copyCmd.MarkPersistentFlagRequired("to")
+}
+
+// defaultStripLabels are the labels of orchestrators, which would manage or monitor the copy as the target.
+var defaultStripLabels = []string{"com.docker.compose.*", "com.docker.swarm.*", "com.docker.stack.*", "io.kubernetes.*"}
+
+// addCopyFlags adds the flags that configure the copy container to flags, appending when to their usage, e.g. to tell
+// which flag enables the copy.
+func addCopyFlags(flags *pflag.FlagSet, when string) {
+	flags.StringArrayVar(&entrypointFlag, "entrypoint", nil, "(optional) The entrypoint to run when starting the debug container"+when)
+	flags.StringArrayVar(&cmdFlag, "cmd", nil, "(optional) The command to run when starting the debug container"+when)
+	flags.StringArrayVar(&appendEntrypointFlag, "append-entrypoint", nil, "(optional) An argument to append to the target's entrypoint instead of replacing it, can be repeated"+when)
+	flags.StringArrayVar(&appendCmdFlag, "append-cmd", nil, "(optional) An argument to append to the target's command instead of replacing it, e.g. --verbose, can be repeated"+when)
+	flags.String("cmd-shell", "", "(optional) A shell command to run in the debug container with the shell of the tools instead of its entrypoint and command, e.g. \"while true; do sleep 1; done\""+when)
+	flags.Bool("no-split-cmd", false, "(optional) Keep a single --cmd value containing spaces as one argument instead of splitting it on whitespace"+when)
+	flags.String("entrypoint-file", "", "(optional) A file with the entrypoint of the debug container as a JSON array of strings, instead of --entrypoint"+when)
+	flags.String("cmd-file", "", "(optional) A file with the command of the debug container as a JSON array of strings, instead of --cmd"+when)
+	flags.String("stop-signal", "", "(optional) The signal to stop the copy container with, instead of the target's"+when)
+	flags.Bool("replace", false, "(optional) Remove an existing container with the name of the copy container before creating it"+when)
+	flags.Bool("pause-target", false, "(optional) Pause the target container while the copy container is running"+when)
+	flags.String("log-driver", "", "(optional) The logging driver of the copy container, json-file by default"+when)
+	flags.Bool("inherit-log-driver", false, "(optional) Use the target's logging driver and options for the copy container"+when)
+	flags.String("hostname", "", "(optional) The hostname of the copy container instead of the target's"+when)
+	flags.String("mac-address", "", "(optional) The MAC address of the copy container instead of the target's, ignored if the target is running"+when)
+	flags.StringArrayVar(&addHostFlag, "add-host", nil, "(optional) A host-to-IP mapping in the name:ip format to add to the copy container besides the target's, ignored if the target is running"+when)
+	flags.StringArrayVar(&ulimitFlag, "ulimit", nil, "(optional) A ulimit of the copy container in the name=soft[:hard] format, overriding the target's"+when)
+	flags.String("shm-size", "", "(optional) The size of /dev/shm of the copy container, e.g. 1g, instead of the target's"+when)
+	flags.Bool("oom-kill-disable", false, "(optional) Whether the OOM killer is disabled for the copy container, by default as in the target"+when)
+	flags.Int("oom-score-adj", 0, "(optional) The OOM score adjustment of the copy container between -1000 and 1000, by default the target's"+when)
+	flags.Bool("inherit-io-limits", false, "(optional) Give the copy container the target's block IO weight and device read/write rate limits, e.g. to reproduce IO throttling"+when)
+	flags.Uint16("blkio-weight", 0, "(optional) The block IO weight of the copy container between 10 and 1000, overriding the target's inherited with --inherit-io-limits"+when)
+	flags.StringArrayVar(&deviceReadBpsFlag, "device-read-bps", nil, "(optional) A read rate limit of the copy container in bytes per second in the <device-path>:<rate> format, e.g. /dev/sda:10mb, can be repeated"+when)
+	flags.StringArrayVar(&deviceWriteBpsFlag, "device-write-bps", nil, "(optional) A write rate limit of the copy container in bytes per second in the <device-path>:<rate> format, can be repeated"+when)
+	flags.String("gpus", "", "(optional) The GPUs to add to the copy container besides the target's, e.g. all"+when)
+	flags.Bool("inherit-cgroup", false, "(optional) Put the copy container under the target's cgroup parent, e.g. to reproduce throttling or OOM kills, this affects the target's resource accounting"+when)
+	flags.String("cgroup-parent", "", "(optional) The cgroup parent of the copy container, overriding the target's one inherited with --inherit-cgroup"+when)
+	flags.StringSliceVar(&stripLabelsFlag, "strip-labels", defaultStripLabels, "(optional) The patterns of the target's labels not to copy, by default the orchestrators' so the copy isn't managed or monitored as the real workload"+when)
+	flags.StringArrayVar(&unsetEnvFlag, "unset-env", nil, "(optional) A variable of the target's environment not to copy, or a pattern such as AWS_*, can be repeated"+when)
+	flags.StringArrayVar(&envPassthroughFlag, "env-passthrough", nil, "(optional) Only copy the variables of the target's environment matching this name or pattern, e.g. PATH, can be repeated"+when)
+	flags.Bool("init", false, "(optional) Run an init process as PID 1 of the copy container, by default as in the target"+when)
+	flags.String("host-tools", "", "(optional) A host directory of static binaries, including sh, to mount instead of the tools of --image, nothing is pulled"+when)
+	flags.Bool("read-write", false, "(optional) Give the copy container a writable root filesystem even if the target's is read-only"+when)
+	flags.String("save-session", "", "(optional) Write the resolved configuration of the copy container to this JSON file, to recreate it later with the replay command"+when)
+	flags.Bool("print-run-command", false, "(optional) Print the docker run command equivalent to the copy container"+when)
+	flags.StringArrayVar(&aliasFlag, "alias", nil, "(optional) A network alias to add to the copy container besides the target's, ignored if the target is running"+when)
+	flags.String("base-image", "", "(optional) The image to run the copy container from instead of the target's, e.g. a debug variant of the same image including a shell, pulled unless available locally"+when)
+	flags.Bool("postmortem", false, "(optional) Copy the exact final filesystem of the exited target, committed to a temporary image, and keep the copy running instead of replaying its command, both are removed when the debug session ends"+when)
+	flags.Bool("takeover", false, "(optional) Stop the target container and start the copy with its networks, aliases and published ports until the debug session ends, confirmed first"+when)
+	flags.String("coredump-dir", "", "(optional) Replay the target's command in the copy with core dumps enabled and, once it exits, copy its core dumps to this directory of the host running debug-ctr"+when)
+	flags.Bool("set-core-pattern", false, "(optional) Set the host's core_pattern from a privileged container so the core dumps of --coredump-dir are written, until the debug session ends, confirmed first"+when)
+	flags.BoolP("yes", "y", false, "(optional) Confirm --replace, --takeover and --set-core-pattern without prompting, required for the latter two without a terminal")
+	flags.Bool("entrypoint-probe", true, "(optional) Check the entrypoint exists among the tools before starting the copy container, when it's run from "+debuggerMountPath+when)
+	flags.String("link-tools", "", "(optional) A directory on the PATH of the copy container, e.g. /usr/local/bin, to symlink the tools into so they are found without "+debuggerMountPath+", the existing files are kept"+when)
+	flags.Bool("wait-for-exec", true, "(optional) Wait for the debug shell to be available in the copy container before printing the exec command or opening a terminal"+when)
+	flags.Bool("overwrite", false, "(optional) Let the tools of a later --image replace those with the same name of the earlier ones"+when)
+	flags.Bool("shared-volume", false, "(optional) Share the tools volume between all the targets debugged with the same image"+when)
+	flags.Bool("ephemeral-tools", false, "(optional) Keep the tools in memory, in a tmpfs volume of the copy container, instead of a volume on disk reused across sessions, they are gone once the copy stops"+when)
+}
+
+// readArgsFile reads a JSON array of strings, such as ["/bin/sh", "-c", "echo $HOME"], from the file at path.
+func readArgsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, fmt.Errorf("%s must contain a JSON array of strings: %w", path, err)
+	}
+	return args, nil
+}
+
+// copyOptions holds the settings used to create a "copy" of the target container.
+type copyOptions struct {
+	// debugImages are the images whose tools are layered into the tools volume, the tools of the later images don't
+	// replace those of the earlier ones unless overwrite is set.
+	debugImages       []string
+	overwrite         bool
+	targetContainer   string
+	copyContainerName string
+	// baseImage replaces the target's image as the image of the copy, if set.
+	baseImage string
+	// targetVolumes mounts the target's volumes and bind mounts in the copy, as with --postmortem.
+	targetVolumes bool
+	// usernsMode overrides the target's user namespace mode, if set.
+	usernsMode         container.UsernsMode
+	entrypointOverride []string
+	cmdOverride        []string
+	// appendEntrypoint and appendCmd are appended to the target's entrypoint and command, which are kept.
+	appendEntrypoint []string
+	appendCmd        []string
+	stopSignal       string
+	// sharedVolume reuses a single tools volume for every target debugged with the same image.
+	sharedVolume bool
+	// ephemeralTools keeps the tools in a tmpfs volume specific to the copy, emptied once the copy stops.
+	ephemeralTools bool
+	ociRuntime     string
+	logDriver      string
+	// inheritLogDriver copies the target's logging configuration instead of using json-file, so `docker logs` may not work.
+	inheritLogDriver bool
+	hostname         string
+	// macAddress overrides the target's MAC address, which the copy keeps when it has its own network namespace.
+	macAddress string
+	// shmSize overrides the target's size of /dev/shm, if set.
+	shmSize int64
+	// extraHosts are added to the target's host-to-IP mappings.
+	extraHosts []string
+	// ulimits override the target's ulimits with the same name.
+	ulimits []*units.Ulimit
+	// oomKillDisable and oomScoreAdj override the target's OOM settings, nil inherits them.
+	oomKillDisable *bool
+	oomScoreAdj    *int
+	// inheritIOLimits copies the target's block IO weight and device rate limits.
+	inheritIOLimits bool
+	// blkioWeight overrides the block IO weight, if set.
+	blkioWeight uint16
+	// deviceReadBps and deviceWriteBps override the device rate limits of the same devices.
+	deviceReadBps  []*blkiodev.ThrottleDevice
+	deviceWriteBps []*blkiodev.ThrottleDevice
+	// gpuRequest is added to the target's device requests, if set.
+	gpuRequest *container.DeviceRequest
+	// readWrite gives the copy a writable root filesystem even if the target's is read-only.
+	readWrite bool
+	// printRunCommand prints the `docker run` command equivalent to the copy container.
+	printRunCommand bool
+	// aliases are added to the target's network aliases.
+	aliases []string
+	// inheritCgroup puts the copy under the target's cgroup parent, which affects the target's resource accounting.
+	inheritCgroup bool
+	// cgroupParent is the cgroup parent of the copy, overriding the inherited one.
+	cgroupParent string
+	// stripLabels are the patterns of the target's labels not copied, so the copy isn't mistaken for the real workload.
+	stripLabels []string
+	// unsetEnv are the patterns of the target's environment variables not copied.
+	unsetEnv []string
+	// envPassthrough are the patterns of the only target's environment variables copied, if set.
+	envPassthrough []string
+	// init overrides whether the copy runs an init process as PID 1, nil inherits the target's setting.
+	init *bool
+	// hostTools is a directory of the daemon's host mounted instead of the tools volume, no debug image is used.
+	hostTools string
+	// keepPopulate keeps the container populating the tools volume for troubleshooting debug-ctr itself.
+	keepPopulate bool
+	// coreDumpVolume is mounted at coreDumpMountPath to collect the core dumps of the copy, if set.
+	coreDumpVolume string
+	// saveSession is the file the spec of the copy is written to, to recreate it with the replay command.
+	saveSession string
+	// entrypointProbe checks the executable of the copy exists among the tools before starting it.
+	entrypointProbe bool
+	// publishPorts publishes the target's ports on the copy, only possible once the target is stopped.
+	publishPorts bool
+}
+
+// keepAliveArgs are the entrypoint and command keeping a copy running with the tools, whatever the target's entrypoint.
+var keepAliveArgs = []string{debuggerMountPath + "/sh", "-c", "while :; do " + debuggerMountPath + "/sleep 3600; done"}
+
+// ensureCopyNameAvailable fails early if a container named copyContainerName already exists, so no work is done before
+// ContainerCreate would reject the name. If replace is set, the existing container is force-removed instead, once
+// confirmed unless yes is set.
+func ensureCopyNameAvailable(ctx context.Context, copyContainerName string, replace, yes bool) error {
+	existing, err := cli.ContainerInspect(ctx, copyContainerName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if existing.Name != "/"+copyContainerName {
+		// The daemon also looks containers up by ID prefix, that container doesn't hold the name
+		return nil
+	}
+	if !replace {
+		return fmt.Errorf("a container named %s already exists (%s), remove it or use --replace", copyContainerName, existing.ID[:12])
+	}
+
+	if err := confirm(fmt.Sprintf("--replace removes the existing container %s (%s)", copyContainerName, existing.ID[:12]), yes, true); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Removing existing container %s", copyContainerName))
+	return cli.ContainerRemove(ctx, existing.ID, types.ContainerRemoveOptions{
+		Force: true,
+	})
+}
+
+// createCopyContainer creates a new container (a "copy") that is used to debug.
+// For example, you can't run docker exec to troubleshoot your container if your container image does not include a shell or if your application crashes on startup.
+// In these situations you can use debug-ctr copy to create a copy of the container with configuration values changed to aid debugging.
+func createCopyContainer(ctx context.Context, opts copyOptions) error {
+	inspect, err := cli.ContainerInspect(ctx, opts.targetContainer)
+	if err != nil {
+		return err
+	}
+
+	if isScratchBased(ctx, opts.targetContainer) {
+		source := strings.Join(opts.debugImages, ", ")
+		if opts.hostTools != "" {
+			source = "the host directory " + opts.hostTools
+		}
+		slog.Warn(fmt.Sprintf("%s looks like a scratch-based container (no /lib, /lib64 or /bin/sh): the tools from %s only work if they are statically linked (e.g. busybox), as there is no loader for dynamically linked ones", opts.targetContainer, source))
+	}
+
+	tools := opts.hostTools
+	if tools == "" {
+		if opts.ephemeralTools {
+			tools = ephemeralVolumeName(opts.copyContainerName)
+			release, err := holdEphemeralVolume(ctx, opts.debugImages[0], tools)
+			if err != nil {
+				return err
+			}
+			// The copy keeps the tools once it has started
+			defer release()
+		} else {
+			// Create one volume per container to debug to avoid overwriting binaries, unless the user opted into sharing it
+			tools = debugVolumeName(opts.debugImages, strings.TrimPrefix(inspect.Name, "/"), opts.sharedVolume)
+		}
+		if err := populateTools(ctx, opts, tools); err != nil {
+			return err
+		}
+	}
+
+	// Create the "copy" container
+	config, hostConfig := copyContainerConfig(inspect, opts, tools)
+	if len(opts.unsetEnv) > 0 || len(opts.envPassthrough) > 0 {
+		warnImageEnv(ctx, config.Image, config.Env)
+	}
+
+	// When sharing the network namespace of the running target, the copy is already reachable like the target
+	networkingConfig := &network.NetworkingConfig{}
+	var otherNetworks map[string]*network.EndpointSettings
+	if inspect.State.Running {
+		if len(opts.aliases) > 0 {
+			slog.Warn("ignoring --alias, the copy shares the network namespace of the running target")
+		}
+	} else {
+		networkingConfig, otherNetworks = copyNetworks(inspect, opts.aliases)
+	}
+
+	if opts.entrypointProbe {
+		if err := probeEntrypoint(ctx, opts, tools, executableOf(config.Entrypoint, config.Cmd)); err != nil {
+			return err
+		}
+	}
+
+	if opts.printRunCommand {
+		slog.Info(fmt.Sprintf("Equivalent docker run command:\n%s", dockerRunCommand(opts.copyContainerName, config, hostConfig, networkingConfig)))
+	}
+
+	if opts.saveSession != "" {
+		spec := newSessionSpec(inspect, opts, tools, config, hostConfig, networkingConfig, otherNetworks)
+		if err := writeSessionSpec(opts.saveSession, spec); err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Debug session saved to %s, recreate the copy with: debug-ctr replay %s", opts.saveSession, shellQuote(opts.saveSession)))
+	}
+
+	return startCopyContainer(ctx, opts.copyContainerName, config, hostConfig, networkingConfig, otherNetworks)
+}
+
+// populateTools copies the tools of every debug image into volume, in order.
+func populateTools(ctx context.Context, opts copyOptions, volume string) error {
+	for i, image := range opts.debugImages {
+		// The first image always refreshes its tools, the later ones only add theirs unless asked to overwrite
+		if err := populateToolsVolume(ctx, cli, opts, image, volume, i == 0 || opts.overwrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ephemeralVolumeName returns the name of the tmpfs volume holding the tools of the copy container with --ephemeral-tools.
+func ephemeralVolumeName(copyContainerName string) string {
+	return "debug-ctr-tmpfs-" + sanitizeVolumeName(copyContainerName)
+}
+
+// holdEphemeralVolume creates the tmpfs volume named volume, if needed, and mounts it in a container of image until
+// the returned function is called. The local driver unmounts the tmpfs, losing its content, as soon as no running
+// container uses it, so the volume must be held from before it's populated until the copy container has started.
+//
+// The tmpfs has the default size, half of the memory of the daemon's host, which fits any toolkit. Its pages are
+// only used for the tools actually copied.
+func holdEphemeralVolume(ctx context.Context, image, volume string) (func(), error) {
+	if _, err := cli.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+		Name:   volume,
+		Driver: "local",
+		DriverOpts: map[string]string{
+			"type":   "tmpfs",
+			"device": "tmpfs",
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/sh", "-c", "tail -f /dev/null"}, // keep container running in the background
+	}, &container.HostConfig{
+		Binds: []string{
+			volume + ":" + populateMountPath,
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		if err := cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		}); err != nil {
+			slog.Warn(fmt.Sprintf("could not remove the container holding the tools volume %s", volume), "error", err)
+		}
+	}
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// startCopyContainer creates the copy container named name with the given configuration, connects it to the other
+// networks and starts it.
+func startCopyContainer(ctx context.Context, name string, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, otherNetworks map[string]*network.EndpointSettings) error {
+	start := time.Now()
+	copyContainerCreateResp, err := cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	if err != nil {
+		return err
+	}
+	for networkName, settings := range otherNetworks {
+		if err := cli.NetworkConnect(ctx, networkName, copyContainerCreateResp.ID, settings); err != nil {
+			return err
+		}
+	}
+	emitEvent(event{Type: eventCopyCreated, Container: name, Image: config.Image})
+	recordPhase("create copy", start)
+
+	slog.Debug("Starting debug container", "container", copyContainerCreateResp.ID)
+	start = time.Now()
+	if err := cli.ContainerStart(ctx, copyContainerCreateResp.ID, types.ContainerStartOptions{}); err != nil {
+		return explainStartError(err, executableOf(config.Entrypoint, config.Cmd))
+	}
+	recordPhase("start copy", start)
+	emitEvent(event{Type: eventCopyStarted, Container: name})
+	return nil
+}
+
+// checkHostTools validates the --host-tools directory, returning its absolute path, and warns about the binaries
+// that are dynamically linked, as the target may not have the loader and libraries they need.
+func checkHostTools(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid --host-tools: %w", err)
+	}
+
+	var dynamic []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		f, err := elf.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// Not an ELF binary, e.g. a script
+			continue
+		}
+		for _, prog := range f.Progs {
+			if prog.Type == elf.PT_INTERP {
+				dynamic = append(dynamic, entry.Name())
+				break
+			}
+		}
+		_ = f.Close()
+	}
+	if len(dynamic) > 0 {
+		slog.Warn(fmt.Sprintf("these tools in %s are dynamically linked and may not run in the copy container: %s", dir, strings.Join(dynamic, ", ")))
+	}
+	return dir, nil
+}
+
+// containerRunner is the part of the Docker client running the container populating the tools volume.
+type containerRunner interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error)
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+}
+
+// populateToolsVolume copies the tools in /bin of image into volume with runner, and returns once they are all there.
+// Unless overwrite is set, the tools already in volume are kept.
+func populateToolsVolume(ctx context.Context, runner containerRunner, opts copyOptions, image, volume string, overwrite bool) error {
+	// Copy the tools explicitly rather than relying on Docker seeding the volume from the image, which only happens
+	// while it's empty. tar keeps the hard links of multi-call binaries such as busybox, unlike cp -a.
+	script := populateScript
+	if !overwrite {
+		script = populateNewScript
+	}
+	start := time.Now()
+	resp, err := runner.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/sh", "-c", script},
+	}, &container.HostConfig{
+		AutoRemove: !opts.keepPopulate,
+		Binds: []string{
+			volume + ":" + populateMountPath,
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+
+	// Wait before starting it, the container is removed as soon as it exits
+	waitCondition := container.WaitConditionRemoved
+	if opts.keepPopulate {
+		waitCondition = container.WaitConditionNextExit
+	}
+	statusCh, errCh := runner.ContainerWait(ctx, resp.ID, waitCondition)
+	if err := runner.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+	// The copy mounts the same volume, it must not start before the tools are all there
+	if err := waitForPopulate(statusCh, errCh, populateTimeout); err != nil {
+		if !opts.keepPopulate {
+			// e.g. still running after the timeout
+			_ = runner.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{Force: true})
+		}
+		return err
+	}
+	recordPhase("populate "+image, start)
+	if opts.keepPopulate {
+		printKeptContainer("populate", resp.ID)
+	}
+	emitEvent(event{Type: eventVolumePopulated, Image: image, Volume: volume})
+	return nil
+}
+
+// populateScript copies the tools in /bin into the volume at populateMountPath, replacing the existing ones.
+const populateScript = "tar -C /bin -cf - . | tar -C " + populateMountPath + " -xf -"
+
+// populateNewScript copies the tools in /bin that aren't in the volume at populateMountPath yet. The list is checked
+// for emptiness first, as busybox tar refuses to create an empty archive.
+const populateNewScript = `set -e
+cd /bin
+for f in * .[!.]*; do
+  [ -e "$f" ] || [ -L "$f" ] || continue
+  [ -e "` + populateMountPath + `/$f" ] || [ -L "` + populateMountPath + `/$f" ] || echo "$f"
+done > /tmp/debug-ctr-new-tools
+[ -s /tmp/debug-ctr-new-tools ] || exit 0
+tar -cf - -T /tmp/debug-ctr-new-tools | tar -C ` + populateMountPath + ` -xf -`
+
+// probeEntrypoint fails if executable is meant to run from the tools, i.e. from debuggerMountPath, but isn't an
+// executable there, e.g. --entrypoint=/.debugger/sleep with a debug image without sleep. Otherwise the copy would fail
+// to start with an opaque error from the runtime.
+func probeEntrypoint(ctx context.Context, opts copyOptions, tools, executable string) error {
+	rel := strings.TrimPrefix(executable, debuggerMountPath+"/")
+	if rel == executable {
+		return nil
+	}
+
+	missing := func() error {
+		hint := ""
+		if rel != "sh" {
+			hint = fmt.Sprintf(", e.g. use %s/sh", debuggerMountPath)
+		}
+		return fmt.Errorf("the entrypoint %s is not an executable among the tools%s", executable, hint)
+	}
+
+	if opts.hostTools != "" {
+		if info, err := os.Stat(filepath.Join(tools, rel)); err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			return missing()
+		}
+		return nil
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      opts.debugImages[0],
+		Entrypoint: []string{"/bin/sh", "-c", `test -f "$1" && test -x "$1"`, "sh", path.Join(populateMountPath, rel)},
+	}, &container.HostConfig{
+		Binds: []string{
+			tools + ":" + populateMountPath + ":ro",
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		})
+	}()
+
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not check the entrypoint %s: %w", executable, err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return missing()
+		}
+	}
+	return nil
+}
+
+// populateMountPath is where the tools volume is mounted in the container populating it.
+const populateMountPath = "/mnt"
+
+// populateTimeout is how long to wait for the container populating the tools volume to exit. Its tar command always
+// exits, a container still running is stuck, e.g. on a hung volume driver.
+const populateTimeout = 30 * time.Second
+
+// waitForPopulate waits up to timeout for the container populating the tools volume to exit, given the channels
+// returned by ContainerWait, and fails if it didn't exit successfully. The copy must not start with a partial volume.
+func waitForPopulate(statusCh <-chan container.ContainerWaitOKBody, errCh <-chan error, timeout time.Duration) error {
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not wait for the tools volume to be populated: %w", err)
+	case status := <-statusCh:
+		if status.Error != nil {
+			return fmt.Errorf("populating the tools volume failed: %s", status.Error.Message)
+		}
+		if status.StatusCode != 0 {
+			return fmt.Errorf("populating the tools volume failed: the container exited with status %d", status.StatusCode)
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("populating the tools volume failed: the container is still running after %s", timeout)
+	}
+}
+
+// waitForShell waits for shell to be available in the copy container, so the exec command works as soon as it's printed.
+func waitForShell(ctx context.Context, copyContainer, shell string) error {
+	deadline := time.Now().Add(populateTimeout)
+	for {
+		_, err := cli.ContainerStatPath(ctx, copyContainer, shell)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the debug shell %s is not available in %s: %w", shell, copyContainer, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// copyContainerConfig returns the configuration of the copy of the target described by inspect, with tools, the tools
+// volume or a host directory, mounted.
+func copyContainerConfig(inspect types.ContainerJSON, opts copyOptions, tools string) (*container.Config, *container.HostConfig) {
+	var containerEntrypoint = inspect.Config.Entrypoint
+	if len(opts.entrypointOverride) > 0 {
+		x := strslice.StrSlice{}
+		for _, y := range opts.entrypointOverride {
+			x = append(x, y)
+		}
+		containerEntrypoint = x
+	}
+	if len(opts.appendEntrypoint) > 0 {
+		containerEntrypoint = append(append(strslice.StrSlice{}, containerEntrypoint...), opts.appendEntrypoint...)
+	}
+	slog.Debug("Copy container entrypoint", "entrypoint", containerEntrypoint)
+
+	var containerCmd = inspect.Config.Cmd
+	if len(opts.cmdOverride) > 0 {
+		x := strslice.StrSlice{}
+		for _, y := range opts.cmdOverride {
+			x = append(x, y)
+		}
+		containerCmd = x
+	}
+	if len(opts.appendCmd) > 0 {
+		containerCmd = append(append(strslice.StrSlice{}, containerCmd...), opts.appendCmd...)
+	}
+	slog.Debug("Copy container command", "cmd", containerCmd)
+	warnMalformedCommand(append(append([]string{}, containerEntrypoint...), containerCmd...))
+
+	stopSignal := inspect.Config.StopSignal
+	if opts.stopSignal != "" {
+		stopSignal = opts.stopSignal
+	}
+
+	target := "container:" + opts.targetContainer
+
+	var debugImages []string
+	if opts.hostTools == "" {
+		debugImages = opts.debugImages
+	}
+
+	var volumesFrom []string
+	if opts.targetVolumes {
+		volumesFrom = []string{opts.targetContainer}
+	}
+
+	hostConfig := &container.HostConfig{
+		Binds: []string{
+			tools + ":" + debuggerMountPath,
+		},
+		VolumesFrom: volumesFrom,
+		// The tools volume is a separate mount, so it stays accessible under a read-only root filesystem
+		ReadonlyRootfs: inspect.HostConfig.ReadonlyRootfs && !opts.readWrite,
+		// Databases and browsers fail in subtle ways with the default 64MB
+		ShmSize:   inspect.HostConfig.ShmSize,
+		Runtime:   opts.ociRuntime,
+		LogConfig: copyLogConfig(inspect.HostConfig.LogConfig, opts.logDriver, opts.inheritLogDriver),
+		Resources: container.Resources{
+			// Keep the same limits as the target to reproduce limit-related failures
+			Ulimits: mergeUlimits(inspect.HostConfig.Ulimits, opts.ulimits),
+			// Give the copy access to the same devices and GPUs, e.g. for CUDA-dependent startups
+			Devices:        inspect.HostConfig.Devices,
+			DeviceRequests: inspect.HostConfig.DeviceRequests,
+			// Let the kernel treat the copy as the target when memory runs out, to reproduce OOM kills
+			OomKillDisable: inspect.HostConfig.OomKillDisable,
+		},
+		OomScoreAdj: inspect.HostConfig.OomScoreAdj,
+	}
+	// Files owned by the target's users keep the same owners in the copy
+	hostConfig.UsernsMode = inspect.HostConfig.UsernsMode
+	if opts.usernsMode != "" {
+		hostConfig.UsernsMode = opts.usernsMode
+	}
+	if opts.inheritIOLimits {
+		hostConfig.BlkioWeight = inspect.HostConfig.BlkioWeight
+		hostConfig.BlkioWeightDevice = inspect.HostConfig.BlkioWeightDevice
+		hostConfig.BlkioDeviceReadBps = inspect.HostConfig.BlkioDeviceReadBps
+		hostConfig.BlkioDeviceWriteBps = inspect.HostConfig.BlkioDeviceWriteBps
+		hostConfig.BlkioDeviceReadIOps = inspect.HostConfig.BlkioDeviceReadIOps
+		hostConfig.BlkioDeviceWriteIOps = inspect.HostConfig.BlkioDeviceWriteIOps
+	}
+	if opts.blkioWeight != 0 {
+		hostConfig.BlkioWeight = opts.blkioWeight
+	}
+	hostConfig.BlkioDeviceReadBps = mergeThrottleDevices(hostConfig.BlkioDeviceReadBps, opts.deviceReadBps)
+	hostConfig.BlkioDeviceWriteBps = mergeThrottleDevices(hostConfig.BlkioDeviceWriteBps, opts.deviceWriteBps)
+	if opts.oomKillDisable != nil {
+		hostConfig.OomKillDisable = opts.oomKillDisable
+	}
+	if opts.oomScoreAdj != nil {
+		hostConfig.OomScoreAdj = *opts.oomScoreAdj
+	}
+	if opts.coreDumpVolume != "" {
+		hostConfig.Binds = append(hostConfig.Binds, opts.coreDumpVolume+":"+coreDumpMountPath)
+	}
+	if opts.shmSize > 0 {
+		hostConfig.ShmSize = opts.shmSize
+	}
+	if opts.gpuRequest != nil {
+		hostConfig.DeviceRequests = append(hostConfig.DeviceRequests, *opts.gpuRequest)
+	}
+	// Keep the same PID 1 as the target, nil leaves it to the daemon's default
+	hostConfig.Init = inspect.HostConfig.Init
+	if opts.init != nil {
+		hostConfig.Init = opts.init
+	}
+	if opts.inheritCgroup {
+		hostConfig.CgroupParent = inspect.HostConfig.CgroupParent
+	}
+	if opts.cgroupParent != "" {
+		hostConfig.CgroupParent = opts.cgroupParent
+	}
+
+	hostname, domainname := inspect.Config.Hostname, inspect.Config.Domainname
+	if opts.hostname != "" {
+		hostname = opts.hostname
+	}
+	macAddress := inspect.Config.MacAddress
+	if opts.macAddress != "" {
+		macAddress = opts.macAddress
+	}
+
+	var exposedPorts nat.PortSet
+	if !inspect.State.Running {
+		// The daemon rejects exposed ports with the network mode of a container, so only the copies with their own
+		// network stack get them
+		exposedPorts = inspect.Config.ExposedPorts
+		// Attach the copy to the target's network, see copyNetworks for the aliases
+		hostConfig.NetworkMode = inspect.HostConfig.NetworkMode
+		hostConfig.Links = copyLinks(inspect.HostConfig.Links)
+		if opts.publishPorts {
+			hostConfig.PortBindings = inspect.HostConfig.PortBindings
+		}
+		// Resolve the same names as the target, /etc/hosts is managed by Docker
+		hostConfig.ExtraHosts = append(append([]string{}, inspect.HostConfig.ExtraHosts...), opts.extraHosts...)
+		// e.g. for licenses bound to the MAC address, only containers with their own network stack have one
+		if macAddress != "" && !isolatedNetworkMode(hostConfig.NetworkMode) {
+			slog.Warn(fmt.Sprintf("ignoring the MAC address %s, it can't be set with the %s network mode", macAddress, hostConfig.NetworkMode))
+			macAddress = ""
+		}
+	} else {
+		hostConfig.NetworkMode = container.NetworkMode(target)
+		hostConfig.PidMode = container.PidMode(target)
+		hostConfig.UTSMode = container.UTSMode(target)
+
+		// The hostname can't be set when sharing the target's namespaces, the copy already has the target's one
+		if opts.hostname != "" {
+			slog.Warn(fmt.Sprintf("ignoring --hostname=%s, the copy shares the network and UTS namespaces of the running target", opts.hostname))
+		}
+		if opts.macAddress != "" {
+			slog.Warn(fmt.Sprintf("ignoring --mac-address=%s, the copy shares the network namespace of the running target", opts.macAddress))
+		}
+		// The copy uses the target's /etc/hosts, entries can't be added when sharing its network namespace
+		if len(opts.extraHosts) > 0 {
+			slog.Warn("ignoring --add-host, the copy shares the network namespace of the running target")
+		}
+		hostname, domainname, macAddress = "", "", ""
+	}
+
+	image := inspect.Image
+	if opts.baseImage != "" {
+		image = opts.baseImage
+	}
+
+	config := &container.Config{
+		ExposedPorts: exposedPorts,
+		Hostname:     hostname,
+		Domainname:   domainname,
+		MacAddress:   macAddress,
+		Image:        image,
+		User:         inspect.Config.User,
+		Env:          copyEnv(inspect.Config.Env, opts.unsetEnv, opts.envPassthrough),
+		Entrypoint:   containerEntrypoint,
+		Cmd:          containerCmd,
+		WorkingDir:   inspect.Config.WorkingDir,
+		Labels:       copyLabels(inspect.Config.Labels, strings.TrimPrefix(inspect.Name, "/"), opts.stripLabels, debugImages),
+		// Keep the same termination behaviour as the target to reproduce graceful-shutdown issues
+		StopSignal:  stopSignal,
+		StopTimeout: inspect.Config.StopTimeout,
+	}
+	return config, hostConfig
+}
+
+// isolatedNetworkMode reports whether containers in networkMode have their own network stack, so their MAC address
+// can be set.
+func isolatedNetworkMode(networkMode container.NetworkMode) bool {
+	return !networkMode.IsHost() && !networkMode.IsNone() && !networkMode.IsContainer()
+}
+
+// isScratchBased reports whether the container's filesystem has neither a shell nor the usual library directories,
+// e.g. images built FROM scratch, where the copied tools can't rely on a dynamic loader.
+func isScratchBased(ctx context.Context, containerName string) bool {
+	for _, path := range []string{"/lib", "/lib64", "/bin/sh"} {
+		if _, err := cli.ContainerStatPath(ctx, containerName, path); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// copyLogConfig returns the logging configuration of the copy container.
+// It defaults to json-file so `docker logs` always works on the copy, unless the target's configuration is inherited
+// or another driver is requested with --log-driver.
+func copyLogConfig(targetLogConfig container.LogConfig, logDriver string, inherit bool) container.LogConfig {
+	logConfig := container.LogConfig{Type: "json-file"}
+	if inherit {
+		logConfig = targetLogConfig
+	}
+	if logDriver != "" && logDriver != logConfig.Type {
+		// The options of the inherited driver don't apply to a different one
+		logConfig = container.LogConfig{Type: logDriver}
+	}
+	return logConfig
+}
+
+// splitCmd splits a single --cmd value containing spaces, e.g. --cmd="sleep 365d", into its arguments.
+// Several values are kept as they are, the user already split them.
+func splitCmd(cmdOverride []string) []string {
+	if len(cmdOverride) != 1 || !strings.ContainsAny(cmdOverride[0], " \t") {
+		return cmdOverride
+	}
+	return strings.Fields(cmdOverride[0])
+}
+
+// sleepDuration matches the durations accepted by sleep, e.g. 365d or infinity.
+var sleepDuration = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?[smhd]?|infinity)$`)
+
+// warnMalformedCommand warns about commands of the copy container that are likely to exit right away, such as sleep
+// without a duration when --entrypoint and --cmd don't combine as expected.
+func warnMalformedCommand(command []string) {
+	if len(command) == 0 {
+		slog.Warn("the copy container has no entrypoint nor command, it will fail to start")
+		return
+	}
+	if path.Base(command[0]) != "sleep" {
+		return
+	}
+	if len(command) == 1 {
+		slog.Warn(fmt.Sprintf("%s has no duration, the copy container will exit right away, e.g. use --cmd=365d", command[0]))
+		return
+	}
+	for _, arg := range command[1:] {
+		if !sleepDuration.MatchString(arg) {
+			slog.Warn(fmt.Sprintf("%q is not a valid duration for %s, the copy container will exit right away", arg, command[0]))
+		}
+	}
+}
+
+// parseGPUs parses the value of --gpus: "all", a number of GPUs or "device=<id>[,<id>...]".
+func parseGPUs(value string) (*container.DeviceRequest, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	request := &container.DeviceRequest{
+		Capabilities: [][]string{{"gpu"}},
+	}
+	switch {
+	case value == "all":
+		request.Count = -1
+	case strings.HasPrefix(value, "device="):
+		request.DeviceIDs = strings.Split(strings.TrimPrefix(value, "device="), ",")
+	default:
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid --gpus %q: expected all, a number of GPUs or device=<id>[,<id>...]", value)
+		}
+		request.Count = count
+	}
+	return request, nil
+}
+
+// validateExtraHosts validates the values of --add-host, in the name:ip format.
+func validateExtraHosts(values []string) error {
+	for _, v := range values {
+		name, ip, ok := strings.Cut(v, ":")
+		if !ok || name == "" || (net.ParseIP(ip) == nil && ip != "host-gateway") {
+			return fmt.Errorf("invalid --add-host %q: expected name:ip", v)
+		}
+	}
+	return nil
+}
+
+// parseUlimits parses the values of --ulimit, in the name=soft[:hard] format.
+func parseUlimits(values []string) ([]*units.Ulimit, error) {
+	ulimits := make([]*units.Ulimit, 0, len(values))
+	for _, v := range values {
+		ulimit, err := units.ParseUlimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ulimit %q: %w", v, err)
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	return ulimits, nil
+}
+
+// hasUlimit reports whether ulimits has one named name.
+func hasUlimit(ulimits []*units.Ulimit, name string) bool {
+	for _, ulimit := range ulimits {
+		if ulimit.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseThrottleDevices parses the values of the flag named name in the <device-path>:<rate> format, e.g.
+// /dev/sda:10mb, where the rate is in bytes per second.
+func parseThrottleDevices(name string, values []string) ([]*blkiodev.ThrottleDevice, error) {
+	devices := make([]*blkiodev.ThrottleDevice, 0, len(values))
+	for _, v := range values {
+		path, rate, ok := strings.Cut(v, ":")
+		if !ok || !strings.HasPrefix(path, "/dev/") {
+			return nil, fmt.Errorf("invalid --%s %q: expected <device-path>:<rate>", name, v)
+		}
+		r, err := units.RAMInBytes(rate)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("invalid --%s %q: invalid rate %s", name, v, rate)
+		}
+		devices = append(devices, &blkiodev.ThrottleDevice{Path: path, Rate: uint64(r)})
+	}
+	return devices, nil
+}
+
+// mergeThrottleDevices returns the target's device rate limits with the ones in overrides replacing those of the same
+// device.
+func mergeThrottleDevices(targetDevices, overrides []*blkiodev.ThrottleDevice) []*blkiodev.ThrottleDevice {
+	devices := make([]*blkiodev.ThrottleDevice, 0, len(targetDevices)+len(overrides))
+	for _, device := range targetDevices {
+		overridden := false
+		for _, override := range overrides {
+			if override.Path == device.Path {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			devices = append(devices, device)
+		}
+	}
+	return append(devices, overrides...)
+}
+
+// mergeUlimits returns the target's ulimits with the ones in overrides replacing those with the same name.
+func mergeUlimits(targetUlimits, overrides []*units.Ulimit) []*units.Ulimit {
+	ulimits := make([]*units.Ulimit, 0, len(targetUlimits)+len(overrides))
+	for _, ulimit := range targetUlimits {
+		overridden := false
+		for _, override := range overrides {
+			if override.Name == ulimit.Name {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			ulimits = append(ulimits, ulimit)
+		}
+	}
+	return append(ulimits, overrides...)
+}
+
+// copyLabels returns the target's labels, without those matching one of the strip patterns, plus the ones debug-ctr
+// uses to manage the copy container and the images its tools come from, if any.
+func copyLabels(targetLabels map[string]string, targetName string, strip []string, debugImages []string) map[string]string {
+	labels := make(map[string]string, len(targetLabels)+4)
+	for k, v := range targetLabels {
+		if !matchesAny(k, strip) {
+			labels[k] = v
+		}
+	}
+	labels[labelTarget] = targetName
+	labels[labelMountPath] = debuggerMountPath
+	labels[labelShell] = debuggerMountPath + "/sh"
+	if len(debugImages) > 0 {
+		labels[labelImages] = strings.Join(debugImages, ",")
+	}
+	return labels
+}
+
+// copyEnv returns the environment of the copy container: the target's env without the variables matching unset or,
+// if passthrough is set, only those matching it.
+func copyEnv(targetEnv []string, unset, passthrough []string) []string {
+	if len(unset) == 0 && len(passthrough) == 0 {
+		return targetEnv
+	}
+	var env []string
+	for _, kv := range targetEnv {
+		key, _, _ := strings.Cut(kv, "=")
+		if matchesAny(key, unset) || (len(passthrough) > 0 && !matchesAny(key, passthrough)) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// warnImageEnv warns about the variables set by image that env leaves out: the daemon adds the image's environment to
+// the container's, so they can't be removed.
+func warnImageEnv(ctx context.Context, image string, env []string) {
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if err != nil || imageInspect.Config == nil {
+		return
+	}
+	kept := map[string]bool{}
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		kept[key] = true
+	}
+	var fromImage []string
+	for _, kv := range imageInspect.Config.Env {
+		if key, _, _ := strings.Cut(kv, "="); !kept[key] {
+			fromImage = append(fromImage, key)
+		}
+	}
+	if len(fromImage) > 0 {
+		slog.Warn(fmt.Sprintf("%s are set by the image %s, the copy keeps the image's values", strings.Join(fromImage, ", "), image))
+	}
+}
+
+// matchesAny reports whether label matches one of the shell patterns, e.g. io.kubernetes.*.
+func matchesAny(label string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, label); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// linkToolsScript symlinks every tool of the mount into the directory given as first argument, keeping the files
+// that already exist there.
+const linkToolsScript = `set -e
+mkdir -p "$1"
+for f in ` + debuggerMountPath + `/*; do
+  n="$1/${f##*/}"
+  [ -e "$n" ] || [ -L "$n" ] || ln -s "$f" "$n"
+done`
+
+// linkTools symlinks the tools into dir of the running copy container, so `sh` and the tools are found on the PATH.
+func linkTools(ctx context.Context, copyContainer, dir string) error {
+	args := copyExecArgs(debuggerMountPath, debuggerMountPath+"/sh", linkToolsScript)
+	_, err := execOutput(ctx, copyContainer, append(args, "sh", dir))
+	return err
+}
+
+// copyExecCommand returns the `docker exec` command to open a shell in a copy container with the tools in mountPath added to the PATH.
+func copyExecCommand(copyContainer, mountPath, shell string) string {
+	return fmt.Sprintf(`docker exec -it %s %s -c "PATH=\$PATH:%s %s"`, shellQuote(copyContainer), shellQuote(shell), mountPath, shell)
+}
+
+// debugVolumeName returns the name of the volume the tools from debugImages are copied into.
+// By default the volume is keyed on both the images and the target so that copies of different targets never share binaries.
+// The sanitized normalized reference of the first image keeps the name readable, a hash of all of them tells apart the
+// references that are sanitized alike, e.g. reg/a:1 and reg_a_1, and the combinations of images.
+func debugVolumeName(debugImages []string, targetContainer string, shared bool) string {
+	refs := make([]string, 0, len(debugImages))
+	for _, image := range debugImages {
+		ref, err := normalizeImage(image)
+		if err != nil {
+			ref = image
+		}
+		refs = append(refs, ref)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(refs, "\n")))
+	name := "debug-ctr-" + sanitizeVolumeName(refs[0]) + "-" + hex.EncodeToString(sum[:])[:12]
+	if shared {
+		return name
+	}
+	return name + "-" + sanitizeVolumeName(targetContainer)
+}
+
+// normalizeImage returns the fully-qualified form of the image reference, e.g. docker.io/library/busybox:latest for
+// busybox, so the short and long forms of an image share the same tools volume.
+func normalizeImage(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// sanitizeVolumeName replaces the characters that are not allowed in a volume name with underscores.
+func sanitizeVolumeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
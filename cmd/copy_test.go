@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestDebugVolumeNamePerTarget(t *testing.T) {
+	images := []string{"busybox:1.28"}
+	a := debugVolumeName(images, "app-a", false)
+	b := debugVolumeName(images, "app-b", false)
+	if a == b {
+		t.Errorf("distinct targets share the volume %s", a)
+	}
+
+	sharedA := debugVolumeName(images, "app-a", true)
+	sharedB := debugVolumeName(images, "app-b", true)
+	if sharedA != sharedB {
+		t.Errorf("with --shared-volume the targets get the volumes %s and %s, want a single one", sharedA, sharedB)
+	}
+	if sharedA == a {
+		t.Errorf("the shared volume %s is the volume of a single target", sharedA)
+	}
+}
+
+func TestDebugVolumeNameNoCollisions(t *testing.T) {
+	tests := []struct {
+		a, b []string
+	}{
+		{[]string{"reg/a:1"}, []string{"reg_a_1"}},
+		// Sanitized alike as reg.io_a_b_1, only the hash tells them apart
+		{[]string{"reg.io/a/b:1"}, []string{"reg.io/a_b:1"}},
+		{[]string{"busybox", "nicolaka/netshoot"}, []string{"busybox"}},
+		{[]string{"busybox", "nicolaka/netshoot"}, []string{"nicolaka/netshoot", "busybox"}},
+	}
+	for _, tt := range tests {
+		for _, shared := range []bool{false, true} {
+			a := debugVolumeName(tt.a, "app", shared)
+			b := debugVolumeName(tt.b, "app", shared)
+			if a == b {
+				t.Errorf("%q and %q share the volume %s (shared: %t)", tt.a, tt.b, a, shared)
+			}
+		}
+	}
+}
+
+func TestDebugVolumeNameNormalized(t *testing.T) {
+	for _, image := range []string{"busybox:latest", "library/busybox", "docker.io/busybox", "docker.io/library/busybox:latest"} {
+		for _, shared := range []bool{false, true} {
+			want := debugVolumeName([]string{"busybox"}, "app", shared)
+			if got := debugVolumeName([]string{image}, "app", shared); got != want {
+				t.Errorf("%s has the volume %s, want the volume of busybox %s (shared: %t)", image, got, want, shared)
+			}
+		}
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"busybox", "docker.io/library/busybox:latest"},
+		{"busybox:1.28", "docker.io/library/busybox:1.28"},
+		{"nicolaka/netshoot", "docker.io/nicolaka/netshoot:latest"},
+		{"ghcr.io/org/tools:v1", "ghcr.io/org/tools:v1"},
+		{"localhost:5000/tools", "localhost:5000/tools:latest"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeImage(tt.in)
+		if err != nil {
+			t.Errorf("normalizeImage(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeImage(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if _, err := normalizeImage("Invalid:Ref:"); err == nil {
+		t.Error("normalizeImage accepted an invalid reference")
+	}
+}
+
+func TestWaitForPopulate(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  *container.ContainerWaitOKBody
+		err     error
+		wantErr bool
+	}{
+		{name: "exited", status: &container.ContainerWaitOKBody{}},
+		{name: "non-zero exit", status: &container.ContainerWaitOKBody{StatusCode: 2}, wantErr: true},
+		{name: "wait error in status", status: &container.ContainerWaitOKBody{Error: &container.ContainerWaitOKBodyError{Message: "no such container"}}, wantErr: true},
+		{name: "wait failed", err: errors.New("connection reset"), wantErr: true},
+		{name: "still running", wantErr: true},
+	}
+	for _, tt := range tests {
+		statusCh := make(chan container.ContainerWaitOKBody, 1)
+		errCh := make(chan error, 1)
+		if tt.status != nil {
+			statusCh <- *tt.status
+		}
+		if tt.err != nil {
+			errCh <- tt.err
+		}
+		if err := waitForPopulate(statusCh, errCh, 10*time.Millisecond); (err != nil) != tt.wantErr {
+			t.Errorf("%s: waitForPopulate returned %v, want an error: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// fakeRunner records the calls running the populate container, which exits once status is sent.
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   []string
+	status  chan container.ContainerWaitOKBody
+	started chan struct{}
+}
+
+func (r *fakeRunner) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *fakeRunner) ContainerCreate(context.Context, *container.Config, *container.HostConfig, *network.NetworkingConfig, *specs.Platform, string) (container.ContainerCreateCreatedBody, error) {
+	r.record("create")
+	return container.ContainerCreateCreatedBody{ID: "populate"}, nil
+}
+
+func (r *fakeRunner) ContainerWait(context.Context, string, container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+	r.record("wait")
+	return r.status, make(chan error)
+}
+
+func (r *fakeRunner) ContainerStart(context.Context, string, types.ContainerStartOptions) error {
+	r.record("start")
+	close(r.started)
+	return nil
+}
+
+func (r *fakeRunner) ContainerRemove(context.Context, string, types.ContainerRemoveOptions) error {
+	r.record("remove")
+	return nil
+}
+
+// TestPopulateToolsVolumeWaits checks the populate container is waited for before populateToolsVolume returns, so the
+// copy created afterwards never starts with a partial volume.
+func TestPopulateToolsVolumeWaits(t *testing.T) {
+	runner := &fakeRunner{status: make(chan container.ContainerWaitOKBody), started: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- populateToolsVolume(context.Background(), runner, copyOptions{}, "busybox", "tools", true)
+	}()
+
+	<-runner.started
+	select {
+	case err := <-done:
+		t.Fatalf("populateToolsVolume returned %v before the populate container exited", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	runner.status <- container.ContainerWaitOKBody{}
+	if err := <-done; err != nil {
+		t.Fatalf("populateToolsVolume: %v", err)
+	}
+	// The container is removed as soon as it exits, it must be waited for before it starts
+	if want := []string{"create", "wait", "start"}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("the populate container was run with %v, want %v", runner.calls, want)
+	}
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// coreDumpMountPath is where the core dumps volume is mounted in the copy container with --coredump-dir.
+const coreDumpMountPath = "/.debug-ctr-cores"
+
+// coreDumpPattern is the core_pattern set with --set-core-pattern, so the cores land in the core dumps volume.
+const coreDumpPattern = coreDumpMountPath + "/core.%e.%p"
+
+// coreDumpVolumeName returns the name of the volume the copy container writes its core dumps to.
+func coreDumpVolumeName(copyContainerName string) string {
+	return "debug-ctr-cores-" + sanitizeVolumeName(copyContainerName)
+}
+
+// prepareCoreDumps makes the core dumps volume writable by any user of the copy and checks the host's core_pattern
+// writes the cores into it. With setPattern the core_pattern is changed from a privileged container, the returned
+// function restores it and must be deferred. The core_pattern isn't namespaced, changing it affects the whole host.
+func prepareCoreDumps(ctx context.Context, image, volume string, setPattern bool) (func(), error) {
+	// Core dumps are written with the identity of the crashing process, which may not be root
+	pattern, err := runHelper(ctx, image, volume, false, "chmod 1777 "+populateMountPath+" && cat /proc/sys/kernel/core_pattern")
+	if err != nil {
+		return nil, fmt.Errorf("preparing the core dumps volume: %w", err)
+	}
+	pattern = strings.TrimSpace(pattern)
+
+	if !setPattern {
+		if !strings.HasPrefix(pattern, coreDumpMountPath+"/") {
+			slog.Warn(fmt.Sprintf("the host's core_pattern is %q, core dumps won't be written to %s, use --set-core-pattern to change it while the copy runs", pattern, coreDumpMountPath))
+		}
+		return func() {}, nil
+	}
+
+	slog.Info(fmt.Sprintf("Setting the host's core_pattern to %s until the debug session ends, it was %q", coreDumpPattern, pattern))
+	if _, err := runHelper(ctx, image, volume, true, "echo "+shellQuote(coreDumpPattern)+" > /proc/sys/kernel/core_pattern"); err != nil {
+		return nil, fmt.Errorf("setting the host's core_pattern: %w", err)
+	}
+	return func() {
+		slog.Info(fmt.Sprintf("Restoring the host's core_pattern to %q", pattern))
+		// Use a fresh context, ctx may already be cancelled when the session ends
+		if _, err := runHelper(context.Background(), image, volume, true, "echo "+shellQuote(pattern)+" > /proc/sys/kernel/core_pattern"); err != nil {
+			slog.Warn(fmt.Sprintf("could not restore the host's core_pattern to %q", pattern), "error", err)
+		}
+	}, nil
+}
+
+// runHelper runs script with the shell of image, with volume, if any, mounted at populateMountPath, and returns its
+// output. privileged gives it write access to /proc/sys.
+func runHelper(ctx context.Context, image, volume string, privileged bool, script string) (string, error) {
+	hostConfig := &container.HostConfig{
+		Privileged: privileged,
+	}
+	if volume != "" {
+		hostConfig.Binds = []string{volume + ":" + populateMountPath}
+	}
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/sh", "-c", script},
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		})
+	}()
+
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", err
+	}
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		return "", err
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	}
+
+	reader, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, reader); err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("exited with status %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// collectCoreDumps copies the core dumps written by the copy container to dir on the host running debug-ctr.
+func collectCoreDumps(ctx context.Context, copyContainer, dir string) error {
+	reader, _, err := cli.CopyFromContainer(ctx, copyContainer, coreDumpMountPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	n := 0
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.Base(hdr.Name))
+		if err := writeCoreDump(dst, tr); err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Core dump written to %s", dst))
+		n++
+	}
+	if n == 0 {
+		slog.Info(fmt.Sprintf("%s didn't write any core dump", copyContainer))
+	}
+	return nil
+}
+
+// writeCoreDump writes the content of r to the file at path.
+func writeCoreDump(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
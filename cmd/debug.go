@@ -4,27 +4,60 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
+	"os/signal"
 	"runtime"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/strslice"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-units"
+	"github.com/moby/term"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 )
 
-const addMountImage = "justincormack/addmount:latest"
+// defaultAddMountImage is the helper image that adds the mount to the target container, see --addmount-image to pin or mirror it.
+// TODO: pin to justincormack/addmount@sha256:<digest> once the digest of the current latest is verified against the registry
+const defaultAddMountImage = "justincormack/addmount:latest"
+
+const (
+	// debuggerMountPath is where the tools volume is mounted in the copy container.
+	debuggerMountPath = "/.debugger"
+
+	labelPrefix    = "io.github.felipecruz91.debug-ctr."
+	labelTarget    = labelPrefix + "target"
+	labelMountPath = labelPrefix + "mount-path"
+	labelShell     = labelPrefix + "shell"
+	// labelImages lists the images the tools of a copy come from, in the order they were layered.
+	labelImages = labelPrefix + "images"
+	// labelRole tells the debug containers that aren't copies of the target apart, e.g. roleRootfs.
+	labelRole = labelPrefix + "role"
+	// roleRootfs is the role of the containers created with --mount-rootfs.
+	roleRootfs = "rootfs"
+)
 
 var (
-	cli *client.Client
-
-	entrypointFlag []string
-	cmdFlag        []string
+	entrypointFlag        []string
+	cmdFlag               []string
+	appendEntrypointFlag  []string
+	appendCmdFlag         []string
+	fallbackPlatformsFlag []string
+	ulimitFlag            []string
+	aliasFlag             []string
+	stripLabelsFlag       []string
+	unsetEnvFlag          []string
+	envPassthroughFlag    []string
+	addHostFlag           []string
+	deviceReadBpsFlag     []string
+	deviceWriteBpsFlag    []string
 )
 
 var debugCmd = &cobra.Command{
@@ -34,239 +67,866 @@ var debugCmd = &cobra.Command{
 	Example: `
 debug-ctr debug --target=my-distroless	
 debug-ctr debug --image=busybox:1.28 --target=my-distroless
-debug-ctr debug --image=busybox:1.28 --target=my-distroless --open-term
-debug-ctr debug --image=docker.io/alpine:latest --target=my-distroless --copy-to=my-distroless-copy 
-debug-ctr debug --image=docker.io/alpine:latest --target=my-distroless --copy-to=my-distroless-copy --entrypoint="/.debugger/sleep" --cmd="365d"
+debug-ctr debug --image=busybox:1.28 --target=my-distroless --attach
+debug-ctr debug --image=busybox:1.28 --target=my-distroless --target=my-sidecar
+debug-ctr debug --image=busybox:1.28 --target=my-distroless --exec-cmd="ls -la /app"
+debug-ctr debug --image=busybox:1.28 --target=my-distroless --attach --terminal=terminal
 `,
-	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		cli, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-		return err
-	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		openTerm, _ := cmd.PersistentFlags().GetBool("open-term")
-		debugImage, _ := cmd.PersistentFlags().GetString("image")
-		targetContainer, _ := cmd.PersistentFlags().GetString("target")
+		targets, _ := cmd.PersistentFlags().GetStringArray("target")
 		copyContainerName, _ := cmd.PersistentFlags().GetString("copy-to")
-		entryPointOverride := entrypointFlag
-		cmdOverride := cmdFlag
+		return runDebug(cmd, targets, copyContainerName)
+	},
+}
+
+// runDebug runs a debug session for targets, adding the tools with a mount or, if copyContainerName is set, to a copy
+// of the single target. The other settings are read from the flags of cmd, see addSessionFlags and addCopyFlags.
+func runDebug(cmd *cobra.Command, targets []string, copyContainerName string) error {
+	attach, terminal := terminalFlags(cmd.PersistentFlags())
+	debugImages, _ := cmd.PersistentFlags().GetStringArray("image")
+	overwrite, _ := cmd.PersistentFlags().GetBool("overwrite")
+	sharedVolume, _ := cmd.PersistentFlags().GetBool("shared-volume")
+	ephemeralTools, _ := cmd.PersistentFlags().GetBool("ephemeral-tools")
+	execCmd, _ := cmd.PersistentFlags().GetString("exec-cmd")
+	stopSignal, _ := cmd.PersistentFlags().GetString("stop-signal")
+	replace, _ := cmd.PersistentFlags().GetBool("replace")
+	pauseTarget, _ := cmd.PersistentFlags().GetBool("pause-target")
+	ociRuntime, _ := cmd.PersistentFlags().GetString("oci-runtime")
+	logDriver, _ := cmd.PersistentFlags().GetString("log-driver")
+	inheritLogDriver, _ := cmd.PersistentFlags().GetBool("inherit-log-driver")
+	hostname, _ := cmd.PersistentFlags().GetString("hostname")
+	macAddress, _ := cmd.PersistentFlags().GetString("mac-address")
+	followSymlinks, _ := cmd.PersistentFlags().GetBool("follow-symlinks")
+	copyLibs, _ := cmd.PersistentFlags().GetBool("copy-libs")
+	addMountImage, _ := cmd.PersistentFlags().GetString("addmount-image")
+	noPullHelper, _ := cmd.PersistentFlags().GetBool("no-pull-helper")
+	forceMount, _ := cmd.PersistentFlags().GetBool("force")
+	logTail, _ := cmd.PersistentFlags().GetString("tail")
+	logSince, _ := cmd.PersistentFlags().GetString("since")
+	readWrite, _ := cmd.PersistentFlags().GetBool("read-write")
+	printRunCommand, _ := cmd.PersistentFlags().GetBool("print-run-command")
+	takeover, _ := cmd.PersistentFlags().GetBool("takeover")
+	postmortem, _ := cmd.PersistentFlags().GetBool("postmortem")
+	baseImage, _ := cmd.PersistentFlags().GetString("base-image")
+	usernsMode, _ := cmd.PersistentFlags().GetString("userns")
+	waitForExec, _ := cmd.PersistentFlags().GetBool("wait-for-exec")
+	linkToolsDir, _ := cmd.PersistentFlags().GetString("link-tools")
+	keepContainers, _ := cmd.PersistentFlags().GetBool("keep-populate-container")
+	yes, _ := cmd.PersistentFlags().GetBool("yes")
+	inheritCgroup, _ := cmd.PersistentFlags().GetBool("inherit-cgroup")
+	cgroupParent, _ := cmd.PersistentFlags().GetString("cgroup-parent")
+	hostTools, _ := cmd.PersistentFlags().GetString("host-tools")
+	var initOverride *bool
+	if cmd.PersistentFlags().Changed("init") {
+		withInit, _ := cmd.PersistentFlags().GetBool("init")
+		initOverride = &withInit
+	}
+	var oomKillDisableOverride *bool
+	if cmd.PersistentFlags().Changed("oom-kill-disable") {
+		oomKillDisable, _ := cmd.PersistentFlags().GetBool("oom-kill-disable")
+		oomKillDisableOverride = &oomKillDisable
+	}
+	var oomScoreAdjOverride *int
+	if cmd.PersistentFlags().Changed("oom-score-adj") {
+		oomScoreAdj, _ := cmd.PersistentFlags().GetInt("oom-score-adj")
+		if oomScoreAdj < -1000 || oomScoreAdj > 1000 {
+			return fmt.Errorf("invalid --oom-score-adj %d: expected a value between -1000 and 1000", oomScoreAdj)
+		}
+		oomScoreAdjOverride = &oomScoreAdj
+	}
+	gpus, _ := cmd.PersistentFlags().GetString("gpus")
+	postStartScript, _ := cmd.PersistentFlags().GetString("post-start-script")
+	attachStdin, _ := cmd.PersistentFlags().GetBool("attach-stdin")
+	mountRootfs, _ := cmd.PersistentFlags().GetBool("mount-rootfs")
+	targetPID, _ := cmd.PersistentFlags().GetInt("pid")
+	pullTimeout, _ := cmd.PersistentFlags().GetDuration("pull-timeout")
+	entrypointProbe, _ := cmd.PersistentFlags().GetBool("entrypoint-probe")
+	coreDumpDir, _ := cmd.PersistentFlags().GetString("coredump-dir")
+	saveSession, _ := cmd.PersistentFlags().GetString("save-session")
+	setCorePattern, _ := cmd.PersistentFlags().GetBool("set-core-pattern")
+	if postStartScript != "" {
+		if _, err := os.Stat(postStartScript); err != nil {
+			return err
+		}
+	}
+	gpuRequest, err := parseGPUs(gpus)
+	if err != nil {
+		return err
+	}
+	var shmSize int64
+	if value, _ := cmd.PersistentFlags().GetString("shm-size"); value != "" {
+		if shmSize, err = units.RAMInBytes(value); err != nil || shmSize <= 0 {
+			return fmt.Errorf("invalid --shm-size %q: expected a size such as 1g", value)
+		}
+	}
+	ulimits, err := parseUlimits(ulimitFlag)
+	if err != nil {
+		return err
+	}
+	inheritIOLimits, _ := cmd.PersistentFlags().GetBool("inherit-io-limits")
+	blkioWeight, _ := cmd.PersistentFlags().GetUint16("blkio-weight")
+	if blkioWeight != 0 && (blkioWeight < 10 || blkioWeight > 1000) {
+		return fmt.Errorf("invalid --blkio-weight %d: expected a value between 10 and 1000", blkioWeight)
+	}
+	deviceReadBps, err := parseThrottleDevices("device-read-bps", deviceReadBpsFlag)
+	if err != nil {
+		return err
+	}
+	deviceWriteBps, err := parseThrottleDevices("device-write-bps", deviceWriteBpsFlag)
+	if err != nil {
+		return err
+	}
+	if err := validateExtraHosts(addHostFlag); err != nil {
+		return err
+	}
+	if hostTools != "" {
+		if copyContainerName == "" {
+			return fmt.Errorf("--host-tools can only be used with the copy command")
+		}
+		if hostTools, err = checkHostTools(hostTools); err != nil {
+			return err
+		}
+	}
+	if ephemeralTools {
+		if copyContainerName == "" {
+			return fmt.Errorf("--ephemeral-tools can only be used with the copy command")
+		}
+		if hostTools != "" || sharedVolume {
+			return fmt.Errorf("--ephemeral-tools can't be used with --host-tools or --shared-volume")
+		}
+	}
+	if len(debugImages) == 0 {
+		return fmt.Errorf("--image is required")
+	}
+	for i, image := range debugImages {
+		if debugImages[i], err = normalizeImage(image); err != nil {
+			return fmt.Errorf("invalid --image %q: %w", image, err)
+		}
+	}
+	if addMountImage, err = normalizeImage(addMountImage); err != nil {
+		return fmt.Errorf("invalid --addmount-image: %w", err)
+	}
+	debugImage := debugImages[0]
+	if copyContainerName == "" {
+		if err := validateLogTail(logTail); err != nil {
+			return err
+		}
+		if len(debugImages) > 1 {
+			return fmt.Errorf("--image can only be repeated with the copy command")
+		}
+	}
+	if len(unsetEnvFlag) > 0 && len(envPassthroughFlag) > 0 {
+		return fmt.Errorf("--unset-env and --env-passthrough can't be used together")
+	}
+	if copyLibs && !followSymlinks {
+		return fmt.Errorf("--copy-libs can only be used with --follow-symlinks")
+	}
+	if mountRootfs && copyContainerName != "" {
+		return fmt.Errorf("--mount-rootfs and --copy-to can't be used together")
+	}
+	if coreDumpDir != "" {
+		if copyContainerName == "" {
+			return fmt.Errorf("--coredump-dir can only be used with the copy command")
+		}
+		if hostTools != "" {
+			return fmt.Errorf("--coredump-dir can't be used with --host-tools, it needs the shell of --image")
+		}
+		if !hasUlimit(ulimits, "core") {
+			ulimits = append(ulimits, &units.Ulimit{Name: "core", Soft: -1, Hard: -1})
+		}
+	}
+	if setCorePattern {
+		if coreDumpDir == "" {
+			return fmt.Errorf("--set-core-pattern can only be used with --coredump-dir")
+		}
+		if err := confirm("--set-core-pattern changes the core_pattern of the whole host until the debug session ends", yes, false); err != nil {
+			return err
+		}
+	}
+	if takeover {
+		if copyContainerName == "" {
+			return fmt.Errorf("--takeover can only be used with the copy command")
+		}
+		if pauseTarget {
+			return fmt.Errorf("--takeover and --pause-target can't be used together")
+		}
+		if err := confirm("--takeover stops the target container and routes its traffic to the copy until the debug session ends", yes, false); err != nil {
+			return err
+		}
+	}
+	if usernsMode != "" && !container.UsernsMode(usernsMode).IsHost() {
+		return fmt.Errorf("invalid --userns %q: expected host", usernsMode)
+	}
+	if baseImage != "" {
+		if copyContainerName == "" {
+			return fmt.Errorf("--base-image can only be used with the copy command")
+		}
+		if postmortem {
+			return fmt.Errorf("--base-image and --postmortem can't be used together")
+		}
+	}
+	if postmortem {
+		if copyContainerName == "" {
+			return fmt.Errorf("--postmortem can only be used with the copy command")
+		}
+		if takeover || pauseTarget || coreDumpDir != "" {
+			return fmt.Errorf("--postmortem can't be used with --takeover, --pause-target or --coredump-dir, the target must have exited")
+		}
+		if saveSession != "" {
+			return fmt.Errorf("--postmortem can't be used with --save-session, the image of the target's filesystem is removed when the debug session ends")
+		}
+	}
+	entryPointOverride := entrypointFlag
+	if entrypointFile, _ := cmd.PersistentFlags().GetString("entrypoint-file"); entrypointFile != "" {
+		if len(entrypointFlag) > 0 {
+			return fmt.Errorf("--entrypoint and --entrypoint-file can't be used together")
+		}
+		if entryPointOverride, err = readArgsFile(entrypointFile); err != nil {
+			return err
+		}
+	}
+	if len(appendEntrypointFlag) > 0 && len(entryPointOverride) > 0 {
+		return fmt.Errorf("--append-entrypoint can't be used with --entrypoint or --entrypoint-file")
+	}
+	cmdOverride := cmdFlag
+	if cmdFile, _ := cmd.PersistentFlags().GetString("cmd-file"); cmdFile != "" {
+		if len(cmdFlag) > 0 {
+			return fmt.Errorf("--cmd and --cmd-file can't be used together")
+		}
+		if cmdOverride, err = readArgsFile(cmdFile); err != nil {
+			return err
+		}
+	} else if noSplitCmd, _ := cmd.PersistentFlags().GetBool("no-split-cmd"); !noSplitCmd {
+		cmdOverride = splitCmd(cmdOverride)
+	}
+	if len(appendCmdFlag) > 0 && len(cmdOverride) > 0 {
+		return fmt.Errorf("--append-cmd can't be used with --cmd or --cmd-file")
+	}
+	if cmdShell, _ := cmd.PersistentFlags().GetString("cmd-shell"); cmdShell != "" {
+		if len(entryPointOverride) > 0 || len(cmdOverride) > 0 || len(appendEntrypointFlag) > 0 || len(appendCmdFlag) > 0 {
+			return fmt.Errorf("--cmd-shell can't be used with the other flags setting the entrypoint or the command")
+		}
+		// The string is passed as a single argument, nothing to split or quote, and can call the tools by name
+		shellCmd := copyExecArgs(debuggerMountPath, debuggerMountPath+"/sh", cmdShell)
+		entryPointOverride, cmdOverride = shellCmd[:1], shellCmd[1:]
+	}
+	if postmortem && len(entryPointOverride) == 0 && len(cmdOverride) == 0 && len(appendEntrypointFlag) == 0 && len(appendCmdFlag) == 0 {
+		// The target's command already ran into the state being inspected, don't run it again
+		entryPointOverride, cmdOverride = keepAliveArgs[:1], keepAliveArgs[1:]
+	}
+
+	if eventsEnabled {
+		// Events are written to stderr, keep the human-readable logs apart
+		setLogOutput(os.Stdout)
+	}
 
-		ctx := context.Background()
+	ctx := context.Background()
 
-		// Check target container exists
-		_, err := cli.ContainerInspect(ctx, targetContainer)
+	if composeService, _ := cmd.PersistentFlags().GetString("compose-service"); composeService != "" {
+		composeProject, _ := cmd.PersistentFlags().GetString("compose-project")
+		composeIndex, _ := cmd.PersistentFlags().GetInt("compose-index")
+		name, err := resolveComposeService(ctx, composeProject, composeService, composeIndex)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, name)
+	}
+	if service, _ := cmd.PersistentFlags().GetString("service"); service != "" {
+		slot, _ := cmd.PersistentFlags().GetInt("service-slot")
+		name, err := resolveSwarmService(ctx, service, slot)
 		if err != nil {
 			return err
 		}
+		targets = append(targets, name)
+	}
+	if targetPID != 0 {
+		if len(targets) > 0 || copyContainerName != "" || mountRootfs {
+			return fmt.Errorf("--pid can't be used with a target container, --copy-to or --mount-rootfs")
+		}
+		if err := checkLocalPID(targetPID); err != nil {
+			return err
+		}
+	} else if len(targets) == 0 {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fmt.Errorf("--target, --compose-service, --service or --pid is required")
+		}
+		// Only a copy can be made of an exited container
+		name, err := selectTarget(ctx, copyContainerName != "")
+		if err != nil {
+			return err
+		}
+		targets = append(targets, name)
+	}
+	if len(targets) > 1 && copyContainerName != "" {
+		return fmt.Errorf("a copy can only be made of a single target")
+	}
+	if copyContainerName != "" {
+		// Before the pulls start, as confirming --replace may prompt
+		if err := ensureCopyNameAvailable(ctx, copyContainerName, replace, yes); err != nil {
+			return err
+		}
+	}
+
+	// Pull the debug image while the quick pre-flight checks run, the pull usually dominates startup time
+	g, gctx := errgroup.WithContext(ctx)
+	for _, image := range debugImages {
+		image := image
+		g.Go(func() error {
+			if hostTools != "" {
+				// The tools come from the host, there is nothing to pull
+				return nil
+			}
+			pctx, cancel := context.WithTimeout(gctx, pullTimeout)
+			defer cancel()
+			defer recordPhase("pull "+image, time.Now())
+			err := pullImage(pctx, image)
+			if err != nil && pctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("pulling %s took longer than --pull-timeout=%s: %w", image, pullTimeout, err)
+			}
+			return withExitCode(exitCodePullFailed, err)
+		})
+	}
+	if baseImage != "" {
+		g.Go(func() error {
+			// e.g. a debug variant built locally, which can't be pulled
+			if _, _, err := cli.ImageInspectWithRaw(gctx, baseImage); err == nil {
+				return nil
+			}
+			pctx, cancel := context.WithTimeout(gctx, pullTimeout)
+			defer cancel()
+			defer recordPhase("pull "+baseImage, time.Now())
+			err := pullImage(pctx, baseImage)
+			if err != nil && pctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("pulling %s took longer than --pull-timeout=%s: %w", baseImage, pullTimeout, err)
+			}
+			return withExitCode(exitCodePullFailed, err)
+		})
+	}
+	g.Go(func() error {
+		// Check target containers exist, using their canonical name from now on
+		for i, target := range targets {
+			name, err := resolveContainerName(gctx, target)
+			if err != nil {
+				return err
+			}
+			targets[i] = name
+		}
+
+		if ociRuntime != "" {
+			if err := validateRuntime(gctx, ociRuntime); err != nil {
+				return err
+			}
+		}
 
-		if err := pullImage(ctx, debugImage); err != nil {
+		if copyContainerName == "" && !mountRootfs && targetPID == 0 {
+			if _, err := daemonSocketPath(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if debugPeers, _ := cmd.PersistentFlags().GetBool("debug-peers"); debugPeers {
+		if len(targets) != 1 {
+			return fmt.Errorf("--debug-peers needs a single target")
+		}
+		name, err := selectPeer(ctx, targets[0])
+		if err != nil {
 			return err
 		}
+		targets[0] = name
+	}
+	var targetContainer string
+	if len(targets) > 0 {
+		targetContainer = targets[0]
+	}
 
-		debugContainer := targetContainer
-		dockerExecCmd := ""
-		if copyContainerName == "" {
-			if err := addMountToTargetContainer(ctx, debugImage, targetContainer); err != nil {
+	debugContainers := targets
+	// execCommandFor returns the `docker exec` command to debug a container, shellArgs the arguments to run a command with the debug shell
+	// takeoverSession restores the target on interrupts until the session waits for them itself, with --takeover
+	var takeoverSession *targetTakeover
+	var execCommandFor func(debugContainer string) string
+	var shellArgs func(command string) []string
+	if targetPID != 0 {
+		pidContainer, err := createPIDContainer(ctx, debugImage, targetPID, ociRuntime)
+		if err != nil {
+			return err
+		}
+		defer removeDebugContainer(pidContainer)
+		debugContainers = []string{pidContainer[:12]}
+		execCommandFor = func(debugContainer string) string {
+			return fmt.Sprintf("docker exec -it %s %s", shellQuote(debugContainer), strings.Join(pidExecArgs(targetPID, []string{"/bin/sh"}), " "))
+		}
+		shellArgs = func(command string) []string {
+			return pidExecArgs(targetPID, addMountExecArgs(command))
+		}
+	} else if mountRootfs {
+		// The targets are left untouched, the tools run in a new container next to each of them
+		debugContainers = nil
+		for _, target := range targets {
+			rootfsContainer, err := createRootfsContainer(ctx, debugImage, target, ociRuntime)
+			if err != nil {
 				return err
 			}
-			dockerExecCmd = fmt.Sprintf("docker exec -it %s /bin/sh", debugContainer)
-		} else {
+			defer removeDebugContainer(rootfsContainer)
+			slog.Info(fmt.Sprintf("The root filesystem of %s is available at %s in %s", target, rootfsMountPath, rootfsContainer[:12]))
+			debugContainers = append(debugContainers, rootfsContainer[:12])
+		}
+		execCommandFor = func(debugContainer string) string {
+			return addMountExecCommand(debugContainer)
+		}
+		shellArgs = addMountExecArgs
+	} else if copyContainerName == "" {
+		var mountTargets []string
+		for _, target := range targets {
+			if !forceMount && hasToolsMount(ctx, target) {
+				slog.Info(fmt.Sprintf("The tools are already mounted into %s, skipping it (use --force to mount them again)", target))
+				continue
+			}
+			mountTargets = append(mountTargets, target)
+		}
+
+		if len(mountTargets) > 0 {
+			// The toolkit container and the addmount image are set up once for all the targets
+			session, err := newAddMountSession(ctx, addMountOptions{
+				debugImage:     debugImage,
+				ociRuntime:     ociRuntime,
+				followSymlinks: followSymlinks,
+				copyLibs:       copyLibs,
+				addMountImage:  addMountImage,
+				noPullHelper:   noPullHelper,
+				usernsMode:     container.UsernsMode(usernsMode),
+				pullTimeout:    pullTimeout,
+				logLimits:      logLimits{tail: logTail, since: logSince},
+				keepContainers: keepContainers,
+			})
+			if err != nil {
+				return withExitCode(exitCodeMountFailed, err)
+			}
+			defer session.close()
+
+			for _, target := range mountTargets {
+				start := time.Now()
+				if err := session.mount(ctx, target); err != nil {
+					return withExitCode(exitCodeMountFailed, err)
+				}
+				recordPhase("mount "+target, start)
+			}
+		}
+		execCommandFor = func(debugContainer string) string {
+			return addMountExecCommand(debugContainer)
+		}
+		shellArgs = addMountExecArgs
+	} else {
+		if takeover {
+			t, err := takeOver(ctx, targetContainer, copyContainerName)
+			if err != nil {
+				return withExitCode(exitCodeCopyFailed, err)
+			}
+			defer t.restore()
+			// Deferred after restore so it runs first, restore runs anyway once runDebug returns
+			defer t.disarm()
+			takeoverSession = t
+		}
+
+		if postmortem {
+			image, err := commitTarget(ctx, targetContainer)
+			if err != nil {
+				return withExitCode(exitCodeCopyFailed, err)
+			}
+			defer removeCommittedImage(image)
+			baseImage = image
+		}
+
+		var coreDumpVolume string
+		if coreDumpDir != "" {
+			coreDumpVolume = coreDumpVolumeName(copyContainerName)
+			restore, err := prepareCoreDumps(ctx, debugImage, coreDumpVolume, setCorePattern)
+			if err != nil {
+				return withExitCode(exitCodeCopyFailed, err)
+			}
+			defer restore()
+		}
+
+		if err := createCopyContainer(ctx, copyOptions{
+			debugImages:        debugImages,
+			overwrite:          overwrite,
+			targetContainer:    targetContainer,
+			copyContainerName:  copyContainerName,
+			baseImage:          baseImage,
+			targetVolumes:      postmortem,
+			usernsMode:         container.UsernsMode(usernsMode),
+			entrypointOverride: entryPointOverride,
+			cmdOverride:        cmdOverride,
+			appendEntrypoint:   appendEntrypointFlag,
+			appendCmd:          appendCmdFlag,
+			stopSignal:         stopSignal,
+			sharedVolume:       sharedVolume,
+			ephemeralTools:     ephemeralTools,
+			ociRuntime:         ociRuntime,
+			logDriver:          logDriver,
+			inheritLogDriver:   inheritLogDriver,
+			hostname:           hostname,
+			macAddress:         macAddress,
+			extraHosts:         addHostFlag,
+			shmSize:            shmSize,
+			ulimits:            ulimits,
+			gpuRequest:         gpuRequest,
+			readWrite:          readWrite,
+			printRunCommand:    printRunCommand,
+			aliases:            aliasFlag,
+			publishPorts:       takeover,
+			inheritCgroup:      inheritCgroup,
+			hostTools:          hostTools,
+			init:               initOverride,
+			oomKillDisable:     oomKillDisableOverride,
+			oomScoreAdj:        oomScoreAdjOverride,
+			inheritIOLimits:    inheritIOLimits,
+			blkioWeight:        blkioWeight,
+			deviceReadBps:      deviceReadBps,
+			deviceWriteBps:     deviceWriteBps,
+			stripLabels:        stripLabelsFlag,
+			unsetEnv:           unsetEnvFlag,
+			envPassthrough:     envPassthroughFlag,
+			cgroupParent:       cgroupParent,
+			keepPopulate:       keepContainers,
+			entrypointProbe:    entrypointProbe,
+			coreDumpVolume:     coreDumpVolume,
+			saveSession:        saveSession,
+		}); err != nil {
+			return withExitCode(exitCodeCopyFailed, err)
+		}
+		if postmortem {
+			// The committed image is in use until the copy is removed
+			defer removeDebugContainer(copyContainerName)
+		}
+		debugContainers = []string{copyContainerName}
+		if waitForExec {
+			start := time.Now()
+			if err := waitForShell(ctx, copyContainerName, debuggerMountPath+"/sh"); err != nil {
+				return withExitCode(exitCodeCopyFailed, err)
+			}
+			recordPhase("exec ready", start)
+		}
 
-			if err := createCopyContainer(ctx, debugImage, targetContainer, copyContainerName, entryPointOverride, cmdOverride); err != nil {
+		if pauseTarget {
+			slog.Info(fmt.Sprintf("Pausing target container %s", targetContainer))
+			if err := cli.ContainerPause(ctx, targetContainer); err != nil {
 				return err
 			}
-			dockerExecCmd = fmt.Sprintf(`docker exec -it %s /.debugger/sh -c "PATH=\$PATH:/.debugger /.debugger/sh"`, copyContainerName)
-		}
-
-		log.Println("-------------------------------")
-		log.Println("Debug your container:")
-		log.Printf("$ %s", dockerExecCmd)
-		log.Println("-------------------------------")
-
-		if openTerm {
-			switch runtime.GOOS {
-			//TODO: windows
-			//TODO: linux
-			case "darwin":
-
-				args := fmt.Sprintf(`
-		reopen
-        tell current window
-          create tab with default profile
-          tell current session
-            write text "%s"
-          end tell
-        end tell
-      end tell`, strings.ReplaceAll(strings.ReplaceAll(dockerExecCmd, `\`, `\\`), `"`, `\"`))
-
-				err := exec.Command("/usr/bin/osascript", "-e", "tell application \"iTerm\"", "-e", args).Run()
-				if err != nil {
-					log.Fatal(err)
+			defer func() {
+				slog.Info(fmt.Sprintf("Unpausing target container %s", targetContainer))
+				if err := cli.ContainerUnpause(context.Background(), targetContainer); err != nil {
+					slog.Warn(fmt.Sprintf("could not unpause target container %s", targetContainer), "error", err)
 				}
+			}()
+		}
+
+		execCommandFor = func(debugContainer string) string {
+			return copyExecCommand(debugContainer, debuggerMountPath, debuggerMountPath+"/sh")
+		}
+		shellArgs = func(command string) []string {
+			return copyExecArgs(debuggerMountPath, debuggerMountPath+"/sh", command)
+		}
+		if linkToolsDir != "" {
+			if err := linkTools(ctx, copyContainerName, linkToolsDir); err != nil {
+				// e.g. a read-only root filesystem or a non-root user, the tools are still in the mount
+				slog.Warn(fmt.Sprintf("could not link the tools into %s, use them from %s instead", linkToolsDir, debuggerMountPath), "error", err)
+			} else {
+				execCommandFor = func(debugContainer string) string {
+					return fmt.Sprintf("docker exec -it %s sh", shellQuote(debugContainer))
+				}
+			}
+		}
+	}
+
+	printTimings()
+
+	if postStartScript != "" {
+		for _, debugContainer := range debugContainers {
+			if err := runPostStartScript(ctx, debugContainer, postStartScript, shellArgs); err != nil {
+				return err
 			}
 		}
+	}
 
+	if execCmd != "" {
+		var stdin io.Reader
+		if attachStdin {
+			if len(debugContainers) > 1 {
+				return fmt.Errorf("--attach-stdin can only be used with a single --target")
+			}
+			stdin = os.Stdin
+		}
+		// Run the command in every container and exit with the last non-zero exit code
+		lastExitCode := 0
+		for _, debugContainer := range debugContainers {
+			exitCode, err := runExecCommand(ctx, debugContainer, shellArgs(execCmd), stdin)
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				lastExitCode = exitCode
+			}
+		}
+		if lastExitCode != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &exitCodeError{code: lastExitCode}
+		}
 		return nil
-	},
+	}
+
+	for _, debugContainer := range debugContainers {
+		dockerExecCmd := execCommandFor(debugContainer)
+		emitEvent(event{Type: eventExecReady, Container: debugContainer, Command: dockerExecCmd})
+		printDebugCommand(dockerExecCmd)
+
+		if attach {
+			if err := attachShell(ctx, terminal, dockerExecCmd, debugContainer, shellArgs("exec sh")); err != nil {
+				// The debug container is ready, the printed command can still be run manually
+				slog.Warn("could not open a terminal, run the command above instead", "error", err)
+			}
+		}
+	}
+
+	if takeoverSession != nil {
+		takeoverSession.disarm()
+	}
+	if coreDumpDir != "" {
+		slog.Info(fmt.Sprintf("Waiting for %s to exit to collect its core dumps into %s, press Ctrl+C to collect them earlier", copyContainerName, coreDumpDir))
+		if err := waitForContainerOrSignal(ctx, copyContainerName); err != nil {
+			return err
+		}
+		return collectCoreDumps(ctx, copyContainerName, coreDumpDir)
+	}
+	if pauseTarget && copyContainerName != "" {
+		slog.Info(fmt.Sprintf("Target container %s stays paused until %s stops or you press Ctrl+C", targetContainer, copyContainerName))
+		return waitForContainerOrSignal(ctx, copyContainerName)
+	}
+	if mountRootfs || targetPID != 0 {
+		slog.Info("The debug containers are removed when you press Ctrl+C")
+		return waitForSignal(ctx)
+	}
+	if postmortem {
+		slog.Info(fmt.Sprintf("%s and the image of the final filesystem of %s are removed when it stops or you press Ctrl+C", copyContainerName, targetContainer))
+		return waitForContainerOrSignal(ctx, copyContainerName)
+	}
+	if takeover {
+		slog.Info(fmt.Sprintf("Target container %s stays stopped until %s stops or you press Ctrl+C", targetContainer, copyContainerName))
+		return waitForContainerOrSignal(ctx, copyContainerName)
+	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(debugCmd)
 
-	debugCmd.PersistentFlags().Bool("open-term", false, "(optional) Open a host terminal to shell into the container automatically")
-	debugCmd.PersistentFlags().String("image", "docker.io/library/busybox:latest", "(optional) The image to use for debugging purposes")
-	debugCmd.PersistentFlags().String("target", "", "(required) The target container to debug")
+	addSessionFlags(debugCmd.PersistentFlags())
+	debugCmd.PersistentFlags().StringArray("target", nil, "(required unless --compose-service or --service is specified, otherwise picked from a list on a terminal) The target container to debug, can be repeated to add the tools to several containers (if --copy-to is not specified)")
 	debugCmd.PersistentFlags().String("copy-to", "", "(optional) The name of the copy container")
-	debugCmd.PersistentFlags().StringArrayVar(&entrypointFlag, "entrypoint", nil, "(optional) The entrypoint to run when starting the debug container (if --copy-to is specified)")
-	debugCmd.PersistentFlags().StringArrayVar(&cmdFlag, "cmd", nil, "(optional) The command to run when starting the debug container (if --copy-to is specified)")
+	_ = debugCmd.PersistentFlags().MarkDeprecated("copy-to", "use the copy command instead")
+	addCopyFlags(debugCmd.PersistentFlags(), " (if --copy-to is specified)")
+	debugCmd.PersistentFlags().Int("pid", 0, "(optional) Debug a process of the host instead of a container, from a privileged container entering its network, UTS and IPC namespaces with nsenter, the Docker daemon must be local")
+	debugCmd.PersistentFlags().Bool("mount-rootfs", false, "(optional) Leave the target untouched and run the debug image in a new container with the target's root filesystem mounted read-only at "+rootfsMountPath+" (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().Bool("follow-symlinks", false, "(optional) Resolve the symlinks of the tools and stage the loader and libraries they need (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().Bool("copy-libs", false, "(optional) With --follow-symlinks, copy the staged libraries the target lacks into its root filesystem, where they stay until it's removed (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().String("addmount-image", defaultAddMountImage, "(optional) The addmount helper image, e.g. pinned by digest or from an internal registry (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().Bool("force", false, "(optional) Mount the tools again into targets that already have them from a previous run (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().Bool("no-pull-helper", false, "(optional) Use the local addmount image instead of pulling it (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().String("tail", "50", "(optional) Number of lines to show from the end of the toolkit and addmount logs when adding the mount fails, or all (if --copy-to is not specified)")
+	debugCmd.PersistentFlags().String("since", "", "(optional) Only show the toolkit and addmount logs since a timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m) when adding the mount fails (if --copy-to is not specified)")
+}
 
-	_ = debugCmd.MarkPersistentFlagRequired("target")
+// addSessionFlags adds the flags shared by the debug and copy commands to flags.
+func addSessionFlags(flags *pflag.FlagSet) {
+	addTerminalFlags(flags)
+	flags.StringArray("image", []string{"docker.io/library/busybox:latest"}, "(optional) The image to use for debugging purposes, can be repeated with the copy command to layer the tools of several images")
+	flags.String("exec-cmd", "", "(optional) Run this command in the debug container, print its output and exit with its exit code instead of opening an interactive shell")
+	flags.Duration("pull-timeout", 10*time.Minute, "(optional) How long pulling the debug and addmount images may take, large images can legitimately take minutes")
+	flags.String("userns", "", "(optional) The user namespace mode of the copy and addmount containers, host to opt out of the daemon's userns-remap, by default the copy's is the target's")
+	flags.String("oci-runtime", "", "(optional) The OCI runtime (e.g. runsc, kata) to run the copy and addmount containers with")
+	flags.StringSliceVar(&fallbackPlatformsFlag, "fallback-platforms", nil, "(optional) The platforms (e.g. linux/amd64) to try in order when an image isn't available for the host's platform, by default Docker picks one")
+	flags.Bool("attach-stdin", false, "(optional) Pipe the standard input of debug-ctr to --exec-cmd, e.g. to run a local script with --exec-cmd=/bin/sh")
+	flags.String("post-start-script", "", "(optional) A local shell script to run once in the debug container before the debug session, without copying it there, e.g. to install extra tools")
+	flags.Bool("debug-peers", false, "(optional) Pick the container to debug among the target and the containers related to it: sharing its network namespace, in its Compose project or on its networks")
+	flags.String("compose-service", "", "(optional) The Docker Compose service whose container is the target")
+	flags.String("compose-project", "", "(optional) The Docker Compose project of --compose-service, if the service exists in several projects")
+	flags.Int("compose-index", 0, "(optional) The replica of --compose-service to debug, as in its container name (e.g. 2 for myproj-web-2), if the service has several replicas")
+	flags.String("service", "", "(optional) The Docker Swarm service whose task container is the target, the task must run on the node of the Docker daemon")
+	flags.Int("service-slot", 0, "(optional) The slot of the task of --service to debug, if the service has several tasks")
+	flags.Bool("keep-populate-container", false, "(optional) Keep the toolkit, addmount and populate containers to troubleshoot debug-ctr itself")
+	_ = flags.MarkHidden("keep-populate-container")
+	flags.BoolVar(&timingsEnabled, "timings", false, "(optional) Print how long each phase of the debug session took, e.g. pulling the images or populating the tools volume")
+	flags.BoolVar(&eventsEnabled, "events", false, "(optional) Write a JSON object per line to stderr for each step of the debug session, human-readable logs go to stdout instead")
 }
 
-func pullImage(ctx context.Context, image string) error {
-	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{
-		Platform: "linux/" + runtime.GOARCH,
-	})
-	if err != nil {
-		return err
+// printDebugCommand prints the command the user should run to debug their container.
+func printDebugCommand(dockerExecCmd string) {
+	if logFormat == logFormatJSON {
+		slog.Info("Debug your container", "command", dockerExecCmd)
+		return
 	}
-	_, err = io.Copy(os.Stdout, reader)
-	return err
+	slog.Info("-------------------------------")
+	slog.Info("Debug your container:")
+	slog.Info("$ " + dockerExecCmd)
+	slog.Info("-------------------------------")
 }
 
-// addMountToTargetContainer mounts the tools from a running container (e.g. `busybox`) into the target container **without** having to restart it.
-// The benefit of this approach is that you wouldn't lose the running state of the container and the tools are available in the target container.
-func addMountToTargetContainer(ctx context.Context, debugImage, targetContainer string) error {
-	// Run toolkit image
-	toolkitContainerResp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image:      debugImage,
-		Entrypoint: []string{"/bin/sh", "-c", "tail -f /dev/null"}, // keep container running in the background
-	}, nil, nil, nil, "")
-	if err != nil {
-		return err
+// printKeptContainer prints the ID of an intermediate container kept with --keep-populate-container, so it can be inspected.
+func printKeptContainer(role, containerID string) {
+	if logFormat == logFormatJSON {
+		slog.Info(fmt.Sprintf("Kept the %s container for troubleshooting", role), "command", "docker inspect "+containerID)
+		return
 	}
-	if err := cli.ContainerStart(ctx, toolkitContainerResp.ID, types.ContainerStartOptions{}); err != nil {
+	slog.Info("-------------------------------")
+	slog.Info(fmt.Sprintf("Kept the %s container for troubleshooting:", role))
+	slog.Info("$ docker inspect " + containerID)
+	slog.Info("-------------------------------")
+}
+
+func pullImage(ctx context.Context, image string) error {
+	platform := "linux/" + runtime.GOARCH
+	err := pullImagePlatform(ctx, cli, image, platform)
+	if err == nil || !isPlatformNotFound(err) {
 		return err
 	}
 
-	// Add mount to the original container
-	if err := pullImage(ctx, addMountImage); err != nil {
-		return err
+	// The image doesn't publish the host's platform, fall back to one that can run under emulation
+	for _, fallback := range fallbackPlatformsFlag {
+		slog.Warn(fmt.Sprintf("image %s is not available for %s, trying %s", image, platform, fallback))
+		err = pullImagePlatform(ctx, cli, image, fallback)
+		if err == nil || !isPlatformNotFound(err) {
+			return err
+		}
 	}
-	addMountContainerResp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image: addMountImage,
-		Cmd:   []string{toolkitContainerResp.ID, "/bin", targetContainer, "/bin"},
-	}, &container.HostConfig{
-		AutoRemove: true,
-		Privileged: true,
-		PidMode:    "host",
-		Binds: []string{
-			"/var/run/docker.sock:/var/run/docker.sock",
-		},
-	}, nil, nil, "")
-	if err != nil {
-		return err
+	if len(fallbackPlatformsFlag) == 0 {
+		slog.Warn(fmt.Sprintf("image %s is not available for %s, letting Docker pick the platform", image, platform))
+		return pullImagePlatform(ctx, cli, image, "")
 	}
-	if err := cli.ContainerStart(ctx, addMountContainerResp.ID, types.ContainerStartOptions{}); err != nil {
+	return err
+}
+
+// imagePuller is the part of the Docker client pulling images.
+type imagePuller interface {
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+}
+
+// pullImagePlatform pulls image with puller for the given platform, or the daemon's default one if platform is empty.
+func pullImagePlatform(ctx context.Context, puller imagePuller, image, platform string) error {
+	reader, err := puller.ImagePull(ctx, image, types.ImagePullOptions{
+		Platform: platform,
+	})
+	if err != nil {
+		// The daemon may have sent a partial response along with the error
+		if reader != nil {
+			_ = reader.Close()
+		}
 		return err
 	}
-	statusCh, errCh := cli.ContainerWait(ctx, addMountContainerResp.ID, container.WaitConditionRemoved)
-	select {
-	case err := <-errCh:
-		if err != nil {
-			panic(err)
-		}
-	case <-statusCh:
+	if reader == nil {
+		// Nothing to display, e.g. the image is already up to date
+		emitEvent(event{Type: eventImagePulled, Image: image})
+		return nil
 	}
+	defer reader.Close()
 
-	// Remove the toolkit container
-	if err := cli.ContainerRemove(ctx, toolkitContainerResp.ID, types.ContainerRemoveOptions{
-		Force: true,
-	}); err != nil {
+	// Errors such as a missing platform are reported in the progress stream, not by ImagePull
+	// The progress bars are redrawn with escape sequences, without them every update is printed on its own line
+	fd, _ := term.GetFdInfo(os.Stdout)
+	if err := jsonmessage.DisplayJSONMessagesStream(reader, os.Stdout, fd, useColor(os.Stdout), nil); err != nil {
 		return err
 	}
+	emitEvent(event{Type: eventImagePulled, Image: image})
 	return nil
 }
 
-// createCopyContainer creates a new container (a "copy") that is used to debug.
-// For example, you can't run docker exec to troubleshoot your container if your container image does not include a shell or if your application crashes on startup.
-// In these situations you can use debug-ctr debug with "--copy-to" to create a copy of the container with configuration values changed to aid debugging.
-func createCopyContainer(ctx context.Context, debugImage, targetContainer, copyContainerName string, entryPointOverride, cmdOverride []string) error {
-	// Create one volume per container to debug to avoid overwriting binaries
-	volumeName := strings.Replace(strings.Replace(debugImage, ":", "_", 1), "/", "_", -1)
-	volume := fmt.Sprintf("debug-ctr-%s", volumeName)
-	resp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image: debugImage,
-	}, &container.HostConfig{
-		AutoRemove: true,
-		Binds: []string{
-			volume + ":" + "/bin",
-		},
-	}, nil, nil, "")
-	if err != nil {
-		return err
-	}
+// isPlatformNotFound reports whether err is caused by the image not being published for the requested platform.
+func isPlatformNotFound(err error) bool {
+	return strings.Contains(err.Error(), "no matching manifest")
+}
+
+// waitForContainerOrSignal blocks until the given container stops running or the process is interrupted.
+func waitForContainerOrSignal(ctx context.Context, containerName string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+	statusCh, errCh := cli.ContainerWait(ctx, containerName, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if ctx.Err() != nil {
+			return nil
+		}
 		return err
+	case <-statusCh:
+		return nil
+	case <-ctx.Done():
+		return nil
 	}
+}
+
+// waitForSignal blocks until the process is interrupted.
+func waitForSignal(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	return nil
+}
 
-	// Create the "copy" container
-	inspect, err := cli.ContainerInspect(ctx, targetContainer)
+// resolveContainerName returns the name of the container referenced by ref, which can be a name or an ID prefix
+// as shown by `docker ps`.
+func resolveContainerName(ctx context.Context, ref string) (string, error) {
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	var containerEntrypoint = inspect.Config.Entrypoint
-	if len(entryPointOverride) > 0 {
-		x := strslice.StrSlice{}
-		for _, y := range entryPointOverride {
-			x = append(x, y)
+	var matches []types.Container
+	for _, c := range containers {
+		for _, name := range c.Names {
+			if strings.TrimPrefix(name, "/") == ref {
+				return ref, nil
+			}
 		}
-		containerEntrypoint = x
-	}
-	log.Printf("entrypoint: %+v", containerEntrypoint)
-
-	var containerCmd = inspect.Config.Cmd
-	if len(cmdOverride) > 0 {
-		x := strslice.StrSlice{}
-		for _, y := range cmdOverride {
-			x = append(x, y)
+		if strings.HasPrefix(c.ID, ref) {
+			matches = append(matches, c)
 		}
-		containerCmd = x
-	}
-	log.Printf("containerCmd: %+v", containerCmd)
-
-	target := "container:" + targetContainer
-
-	hostConfig := &container.HostConfig{
-		Binds: []string{
-			volume + ":" + "/.debugger",
-		},
 	}
 
-	if inspect.State.Running {
-		hostConfig.NetworkMode = container.NetworkMode(target)
-		hostConfig.PidMode = container.PidMode(target)
-		hostConfig.UTSMode = container.UTSMode(target)
+	switch len(matches) {
+	case 0:
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("no such container: %s", ref))
+	case 1:
+		if len(matches[0].Names) == 0 {
+			return matches[0].ID, nil
+		}
+		return strings.TrimPrefix(matches[0].Names[0], "/"), nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, c := range matches {
+			ids = append(ids, c.ID[:12])
+		}
+		return "", fmt.Errorf("container ID prefix %s is ambiguous, it matches %s", ref, strings.Join(ids, ", "))
 	}
+}
 
-	copyContainerCreateResp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image:      inspect.Image,
-		User:       inspect.Config.User,
-		Env:        inspect.Config.Env,
-		Entrypoint: containerEntrypoint,
-		Cmd:        containerCmd,
-		WorkingDir: inspect.Config.WorkingDir,
-		Labels:     inspect.Config.Labels,
-	}, hostConfig, nil, nil, copyContainerName)
+// validateRuntime checks the Docker daemon has the OCI runtime named ociRuntime configured.
+func validateRuntime(ctx context.Context, ociRuntime string) error {
+	info, err := cli.Info(ctx)
 	if err != nil {
 		return err
 	}
+	if _, ok := info.Runtimes[ociRuntime]; ok {
+		return nil
+	}
 
-	log.Printf("Starting debug container %s", copyContainerCreateResp.ID)
-	if err := cli.ContainerStart(ctx, copyContainerCreateResp.ID, types.ContainerStartOptions{}); err != nil {
-		return err
+	runtimes := make([]string, 0, len(info.Runtimes))
+	for name := range info.Runtimes {
+		runtimes = append(runtimes, name)
 	}
-	return nil
+	sort.Strings(runtimes)
+	return fmt.Errorf("unknown OCI runtime %q, the Docker daemon supports: %s", ociRuntime, strings.Join(runtimes, ", "))
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// fakePuller returns reader and err from ImagePull.
+type fakePuller struct {
+	reader io.ReadCloser
+	err    error
+}
+
+func (p fakePuller) ImagePull(context.Context, string, types.ImagePullOptions) (io.ReadCloser, error) {
+	return p.reader, p.err
+}
+
+func TestPullImagePlatformNilReader(t *testing.T) {
+	pullErr := errors.New("pull access denied")
+	if err := pullImagePlatform(context.Background(), fakePuller{err: pullErr}, "busybox", ""); !errors.Is(err, pullErr) {
+		t.Errorf("pullImagePlatform with an error and a nil reader returned %v, want %v", err, pullErr)
+	}
+	if err := pullImagePlatform(context.Background(), fakePuller{}, "busybox", ""); err != nil {
+		t.Errorf("pullImagePlatform with a nil reader returned %v, want nil", err)
+	}
+}
+
+// closeRecorder is a pull response recording whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestPullImagePlatformClosesReader(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{name: "pulled", body: `{"status":"Status: Downloaded newer image for busybox:latest"}` + "\n"},
+		{name: "error in the stream", body: `{"errorDetail":{"message":"no matching manifest"},"error":"no matching manifest"}` + "\n"},
+		{name: "partial response with an error", body: `{"status":"Pulling from library/busybox"}` + "\n", err: errors.New("unexpected EOF")},
+	}
+	for _, tt := range tests {
+		reader := &closeRecorder{Reader: strings.NewReader(tt.body)}
+		_ = pullImagePlatform(context.Background(), fakePuller{reader: reader, err: tt.err}, "busybox", "")
+		if !reader.closed {
+			t.Errorf("%s: the pull response was not closed", tt.name)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/spf13/cobra"
+)
+
+// Results of a doctor check.
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// checkResult is the outcome of a doctor check, with a hint to fix it unless it passed.
+type checkResult struct {
+	name   string
+	status string
+	detail string
+	hint   string
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment debug-ctr runs in",
+	Long: `Checks that the Docker daemon is reachable, that its socket can be mounted into the addmount container, that
+containers can share the host's PID namespace and that the debug and addmount images can be pulled. Prints a
+pass/warn/fail report with hints to fix the problems, and exits non-zero if a check failed.`,
+	Example: `
+debug-ctr doctor
+debug-ctr doctor --docker-host=ssh://user@host
+debug-ctr doctor --image=my-registry/busybox:latest --addmount-image=my-registry/addmount:latest
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debugImage, _ := cmd.Flags().GetString("image")
+		addMountImage, _ := cmd.Flags().GetString("addmount-image")
+
+		results := runDoctorChecks(context.Background(), debugImage, addMountImage)
+
+		out := cmd.OutOrStdout()
+		failed := 0
+		for _, r := range results {
+			fmt.Fprintf(out, "[%s] %s: %s\n", r.status, r.name, r.detail)
+			if r.hint != "" {
+				fmt.Fprintf(out, "       %s\n", r.hint)
+			}
+			if r.status == checkFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().String("image", "docker.io/library/busybox:latest", "(optional) The debug image to check, also used to check the host PID namespace")
+	doctorCmd.Flags().String("addmount-image", defaultAddMountImage, "(optional) The addmount helper image to check")
+}
+
+// runDoctorChecks runs the checks in order. The checks that need the daemon are skipped if it's unreachable, and the
+// host PID namespace check if the debug image can't be pulled.
+func runDoctorChecks(ctx context.Context, debugImage, addMountImage string) []checkResult {
+	daemon := checkDaemon(ctx)
+	results := []checkResult{daemon}
+	if daemon.status == checkFail {
+		return results
+	}
+
+	results = append(results, checkSocket())
+	debugPull := checkPull(ctx, "Debug image", debugImage)
+	results = append(results, debugPull, checkPull(ctx, "Addmount image", addMountImage))
+	if debugPull.status == checkFail {
+		return append(results, checkResult{
+			name:   "Host PID namespace",
+			status: checkWarn,
+			detail: "not checked, the debug image is not available",
+		})
+	}
+	return append(results, checkHostPID(ctx, debugImage))
+}
+
+// checkDaemon checks the Docker daemon is reachable and reports the negotiated API version.
+func checkDaemon(ctx context.Context) checkResult {
+	r := checkResult{name: "Docker daemon"}
+	host := cli.DaemonHost()
+	if sshHost != "" {
+		host = sshHost
+	}
+	server, err := cli.ServerVersion(ctx)
+	if err != nil {
+		r.status = checkFail
+		r.detail = fmt.Sprintf("not reachable at %s: %v", host, err)
+		r.hint = "Start the Docker daemon or select another one with --docker-host or DOCKER_HOST."
+		return r
+	}
+	r.status = checkPass
+	r.detail = fmt.Sprintf("Docker %s reachable at %s, API version %s (negotiated)", server.Version, host, cli.ClientVersion())
+	if unsupported := unsupportedAPIFeatures(cli.ClientVersion()); len(unsupported) > 0 {
+		r.status = checkWarn
+		r.detail += ", too old for: " + strings.Join(unsupported, "; ")
+		r.hint = "Upgrade the Docker daemon to use every feature of debug-ctr."
+	}
+	return r
+}
+
+// checkSocket checks the daemon socket can be bind-mounted into the addmount container, which adding a mount needs.
+func checkSocket() checkResult {
+	r := checkResult{name: "Daemon socket"}
+	socket, err := daemonSocketPath()
+	if err != nil {
+		// The copy command still works, only adding a mount doesn't
+		r.status = checkWarn
+		r.detail = err.Error()
+		r.hint = "Adding a mount with the debug command won't work with this daemon, use the copy command instead."
+		return r
+	}
+	r.status = checkPass
+	r.detail = fmt.Sprintf("%s on the daemon's host can be mounted into the addmount container", socket)
+	return r
+}
+
+// checkPull checks image can be pulled, without printing the pull progress.
+func checkPull(ctx context.Context, name, image string) checkResult {
+	r := checkResult{name: name}
+	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err == nil {
+		// Errors such as a missing platform are reported in the progress stream, not by ImagePull
+		err = jsonmessage.DisplayJSONMessagesStream(reader, io.Discard, 0, false, nil)
+		_ = reader.Close()
+	}
+	if err != nil {
+		r.status = checkFail
+		r.detail = fmt.Sprintf("%s can't be pulled: %v", image, err)
+		r.hint = "Check the registry is reachable and you are logged in, or mirror the image and select it with --image or --addmount-image."
+		return r
+	}
+	r.status = checkPass
+	r.detail = fmt.Sprintf("%s can be pulled", image)
+	return r
+}
+
+// checkHostPID checks a privileged container sharing the host's PID namespace can run, as the addmount container does.
+func checkHostPID(ctx context.Context, image string) checkResult {
+	r := checkResult{name: "Host PID namespace"}
+	fail := func(err error) checkResult {
+		r.status = checkFail
+		r.detail = fmt.Sprintf("a privileged container can't share the host's PID namespace: %v", err)
+		r.hint = "Rootless Docker, user namespace remapping and security policies prevent it, adding a mount won't work, use the copy command instead."
+		return r
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/sh", "-c", "true"},
+	}, &container.HostConfig{
+		Privileged: true,
+		PidMode:    "host",
+	}, nil, nil, "")
+	if err != nil {
+		return fail(err)
+	}
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		})
+	}()
+
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return fail(err)
+	}
+	select {
+	case err := <-errCh:
+		return fail(err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fail(fmt.Errorf("the container exited with status %d", status.StatusCode))
+		}
+	}
+
+	if rootless, err := isRootless(ctx); err == nil && rootless {
+		r.status = checkWarn
+		r.detail = "available, but the daemon is rootless so only the processes of its user are visible"
+		r.hint = "Adding a mount may fail to reach the target's mount namespace, use the copy command if it does."
+		return r
+	}
+	r.status = checkPass
+	r.detail = "privileged containers can share the host's PID namespace"
+	return r
+}
+
+// isRootless reports whether the Docker daemon runs in rootless mode.
+func isRootless(ctx context.Context) (bool, error) {
+	return hasSecurityOption(ctx, "rootless")
+}
+
+// isUsernsRemapped reports whether the Docker daemon remaps the users of the containers to a user namespace.
+func isUsernsRemapped(ctx context.Context) (bool, error) {
+	return hasSecurityOption(ctx, "userns")
+}
+
+// hasSecurityOption reports whether the security options of the Docker daemon include name, e.g. name=rootless.
+func hasSecurityOption(ctx context.Context, name string) (bool, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, "name="+name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// Exit codes returned by debug-ctr so scripts can tell failures apart.
+const (
+	exitCodeGeneric           = 1
+	exitCodeTargetNotFound    = 3
+	exitCodeDaemonUnreachable = 4
+	exitCodePullFailed        = 5
+	exitCodeMountFailed       = 6
+	exitCodeCopyFailed        = 7
+)
+
+// codedError is an error that makes debug-ctr exit with a specific exit code.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode wraps err so that debug-ctr exits with code, unless err is nil or already carries an exit code.
+func withExitCode(code int, err error) error {
+	var coded *codedError
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	return &codedError{code: code, err: err}
+}
+
+// exitCodeError makes Execute exit with the given code, e.g. to forward the exit code of --exec-cmd.
+// Commands returning it should silence cobra's error and usage output.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitCode returns the exit code for an error returned by a command.
+func exitCode(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	// The daemon being down surfaces from whichever call came first, so it takes precedence over the step that failed
+	if client.IsErrConnectionFailed(err) {
+		return exitCodeDaemonUnreachable
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return exitCodeGeneric
+}
+
+// explainStartError adds guidance to an error of ContainerStart caused by the runtime not finding executable, the
+// first word of the container's command, whose message alone is opaque, e.g. "OCI runtime create failed: ...
+// exec: "/.debugger/sleep": stat /.debugger/sleep: no such file or directory".
+func explainStartError(err error, executable string) error {
+	if !isExecNotFound(err) {
+		return err
+	}
+	if strings.HasPrefix(executable, debuggerMountPath+"/") {
+		return fmt.Errorf("the entrypoint %s was not found in the debug volume, the tools may not have been copied or they don't include %s: %w", executable, path.Base(executable), err)
+	}
+	return fmt.Errorf("the entrypoint %s was not found in the container, check --entrypoint and --cmd: %w", executable, err)
+}
+
+// isExecNotFound reports whether err is the runtime failing to start a container as its executable doesn't exist.
+func isExecNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "exec") && strings.Contains(err.Error(), "no such file or directory")
+}
+
+// executableOf returns the executable a container runs, the first word of its entrypoint or else of its command.
+func executableOf(entrypoint, cmd []string) string {
+	if len(entrypoint) > 0 {
+		return entrypoint[0]
+	}
+	if len(cmd) > 0 {
+		return cmd[0]
+	}
+	return ""
+}
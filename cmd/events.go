@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Types of the lifecycle events emitted with --events.
+const (
+	eventImagePulled     = "image_pulled"
+	eventMountAdded      = "mount_added"
+	eventVolumePopulated = "volume_populated"
+	eventCopyCreated     = "copy_created"
+	eventCopyStarted     = "copy_started"
+	eventExecReady       = "exec_ready"
+)
+
+// event is a lifecycle milestone, written as one JSON object per line.
+type event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Container string    `json:"container,omitempty"`
+	Image     string    `json:"image,omitempty"`
+	Volume    string    `json:"volume,omitempty"`
+	Command   string    `json:"command,omitempty"`
+}
+
+var (
+	eventsEnabled bool
+	eventsMu      sync.Mutex
+)
+
+// emitEvent writes e to stderr if --events is set. Events can be emitted concurrently, e.g. while pulling images.
+func emitEvent(e event) {
+	if !eventsEnabled {
+		return
+	}
+	e.Time = time.Now().UTC()
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	_ = json.NewEncoder(os.Stderr).Encode(e)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// runExecCommand runs cmd non-interactively in the given container, streams its output to
+// stdout/stderr and returns the command's exit code. If stdin is set, it's copied to the command's stdin.
+func runExecCommand(ctx context.Context, containerName string, cmd []string, stdin io.Reader) (int, error) {
+	return execCommand(ctx, containerName, cmd, stdin, os.Stdout, os.Stderr)
+}
+
+// execOutput runs cmd in the given container and returns its stdout, failing if it exits non-zero.
+func execOutput(ctx context.Context, containerName string, cmd []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := execCommand(ctx, containerName, cmd, nil, &stdout, &stderr)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("%s exited with status %d: %s", cmd[0], exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// execCommand runs cmd in the given container, copies stdin, if set, to its input and its output to stdout and stderr
+// and returns its exit code.
+func execCommand(ctx context.Context, containerName string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	execResp, err := cli.ContainerExecCreate(ctx, containerName, types.ExecConfig{
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	attachResp, err := cli.ContainerExecAttach(ctx, execResp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, err
+	}
+	defer attachResp.Close()
+
+	if stdin != nil {
+		// Without a TTY there's no raw mode, closing the write side sends EOF once the input is consumed
+		go func() {
+			_, _ = io.Copy(attachResp.Conn, stdin)
+			_ = attachResp.CloseWrite()
+		}()
+	}
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, attachResp.Reader); err != nil {
+		return 0, err
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// addMountExecArgs returns the arguments to run command with the tools added to the target's /bin.
+func addMountExecArgs(command string) []string {
+	return []string{"/bin/sh", "-c", command}
+}
+
+// copyExecArgs returns the arguments to run command in a copy container with the tools in mountPath added to the PATH.
+func copyExecArgs(mountPath, shell, command string) []string {
+	return []string{shell, "-c", "PATH=$PATH:" + mountPath + "; " + command}
+}
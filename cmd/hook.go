@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// runPostStartScript runs the local script once with the debug shell in the debug container, printing its output. It
+// fails if the script exits non-zero.
+//
+// The script is sourced from the standard input of the shell rather than copied into the container, so nothing is
+// left behind in the target and read-only root filesystems work too. Its commands can't read the standard input.
+func runPostStartScript(ctx context.Context, containerName, script string, shellArgs func(command string) []string) error {
+	content, err := os.ReadFile(script)
+	if err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("Running post-start script %s in %s", script, containerName))
+	exitCode, err := runExecCommand(ctx, containerName, shellArgs(". /dev/stdin"), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("post-start script %s exited with status %d", script, exitCode)
+	}
+	return nil
+}
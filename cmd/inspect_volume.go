@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/spf13/cobra"
+)
+
+var inspectVolumeCmd = &cobra.Command{
+	Use:   "inspect-volume <volume>",
+	Short: "List the contents of a debug volume",
+	Long:  `Lists the files of a volume populated by debug-ctr, with their sizes and symlink targets, to check the tools were copied correctly.`,
+	Example: `
+debug-ctr inspect-volume debug-ctr-docker.io_library_busybox_latest-de68ccc327e5-my-distroless
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listImage, _ := cmd.Flags().GetString("image")
+		volume := args[0]
+
+		ctx := context.Background()
+
+		// Mounting a volume that doesn't exist would silently create an empty one
+		if _, err := cli.VolumeInspect(ctx, volume); err != nil {
+			if client.IsErrNotFound(err) {
+				return fmt.Errorf("volume %s does not exist", volume)
+			}
+			return err
+		}
+
+		if err := pullImage(ctx, listImage); err != nil {
+			return withExitCode(exitCodePullFailed, err)
+		}
+
+		return listVolume(ctx, listImage, volume)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectVolumeCmd)
+
+	inspectVolumeCmd.Flags().String("image", "docker.io/library/busybox:latest", "(optional) The image providing the ls command used to list the volume")
+}
+
+// listVolume prints the contents of volume from a short-lived container that mounts it read-only.
+func listVolume(ctx context.Context, listImage, volume string) error {
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      listImage,
+		Entrypoint: []string{"ls", "-lAR", "/volume"},
+	}, &container.HostConfig{
+		Binds: []string{
+			volume + ":/volume:ro",
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		})
+	}()
+
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+
+	var exitCode int64
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return err
+		}
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	}
+
+	reader, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if _, err := stdcopy.StdCopy(os.Stdout, os.Stderr, reader); err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("listing volume %s failed: ls exited with status %d", volume, exitCode)
+	}
+	return nil
+}
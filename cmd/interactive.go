@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/docker/docker/api/types"
+	"github.com/moby/term"
+)
+
+// execInteractive runs args in containerName with a TTY attached to the terminal debug-ctr runs in, like
+// `docker exec -it`, until it exits. The resizes of the terminal are propagated to the TTY, and the interrupt signals
+// sent to debug-ctr are forwarded to the program in the container instead of stopping debug-ctr.
+func execInteractive(ctx context.Context, containerName string, args []string) error {
+	inFd, inTerm := term.GetFdInfo(os.Stdin)
+	outFd, outTerm := term.GetFdInfo(os.Stdout)
+	if !inTerm || !outTerm {
+		return fmt.Errorf("--terminal=%s needs debug-ctr to run in a terminal", terminalInline)
+	}
+
+	execResp, err := cli.ContainerExecCreate(ctx, containerName, types.ExecConfig{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		Cmd:          args,
+	})
+	if err != nil {
+		return err
+	}
+	attachResp, err := cli.ContainerExecAttach(ctx, execResp.ID, types.ExecStartCheck{Tty: true})
+	if err != nil {
+		return err
+	}
+	defer attachResp.Close()
+
+	state, err := term.SetRawTerminal(inFd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = term.RestoreTerminal(inFd, state)
+	}()
+
+	resize := func() {
+		size, err := term.GetWinsize(outFd)
+		if err != nil || size.Height == 0 || size.Width == 0 {
+			return
+		}
+		_ = cli.ContainerExecResize(ctx, execResp.ID, types.ResizeOptions{
+			Height: uint(size.Height),
+			Width:  uint(size.Width),
+		})
+	}
+	resize()
+
+	signals := make(chan os.Signal, 1)
+	notified := make([]os.Signal, 0, len(execSignalChars)+1)
+	for sig := range execSignalChars {
+		notified = append(notified, sig)
+	}
+	if resizeSignal != nil {
+		notified = append(notified, resizeSignal)
+	}
+	signal.Notify(signals, notified...)
+	defer signal.Stop(signals)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == resizeSignal {
+					resize()
+					continue
+				}
+				// In raw mode the terminal doesn't turn Ctrl+C into a signal, so the signals debug-ctr gets are
+				// sent by other processes: the TTY turns their control character back into the signal
+				_, _ = attachResp.Conn.Write([]byte{execSignalChars[sig]})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		_, _ = io.Copy(attachResp.Conn, os.Stdin)
+	}()
+	// With a TTY the output isn't multiplexed
+	_, err = io.Copy(os.Stdout, attachResp.Reader)
+	return err
+}
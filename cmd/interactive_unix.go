@@ -0,0 +1,17 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// resizeSignal is the signal a terminal sends when its window is resized.
+var resizeSignal os.Signal = syscall.SIGWINCH
+
+// execSignalChars are the signals forwarded to an interactive exec, as the control characters the TTY turns into them.
+var execSignalChars = map[os.Signal]byte{
+	os.Interrupt:    0x03, // Ctrl+C
+	syscall.SIGQUIT: 0x1c, // Ctrl+\
+}
@@ -0,0 +1,11 @@
+package cmd
+
+import "os"
+
+// resizeSignal is nil as Windows consoles don't signal their resizes.
+var resizeSignal os.Signal
+
+// execSignalChars are the signals forwarded to an interactive exec, as the control characters the TTY turns into them.
+var execSignalChars = map[os.Signal]byte{
+	os.Interrupt: 0x03, // Ctrl+C
+}
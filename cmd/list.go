@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/spf13/cobra"
+)
+
+// defaultListFormat is the format of the list subcommand, a table with a column per field of listRecord.
+const defaultListFormat = "table {{.Type}}\t{{.Name}}\t{{.Target}}\t{{.Image}}\t{{.Status}}"
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the copy containers and debug volumes created by debug-ctr",
+	Long: `Lists the copy containers and debug volumes created by debug-ctr.
+--format takes a Go template applied to each record, prefix it with "table" to print a table with a header, as with docker ps.`,
+	Example: `
+debug-ctr list
+debug-ctr list --format '{{.Name}} {{.Target}}'
+debug-ctr list --format 'table {{.Name}}\t{{.Status}}'
+debug-ctr list --format '{{json .}}'
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+
+		records, err := listRecords(context.Background())
+		if err != nil {
+			return err
+		}
+		return printRecords(format, records)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().String("format", defaultListFormat, "(optional) Format the output using a Go template, \"table\" or \"table <template>\" to print a table")
+}
+
+// listRecord is a copy container or a debug volume created by debug-ctr, as seen by the --format template.
+type listRecord struct {
+	// Type is either "container" or "volume".
+	Type   string
+	Name   string
+	Target string
+	Image  string
+	Status string
+}
+
+// listRecords returns the copy containers, found by their labels, and the debug volumes, found by their name prefix.
+func listRecords(ctx context.Context) ([]listRecord, error) {
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelTarget)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var records []listRecord
+	for _, c := range containers {
+		if c.Labels[labelRole] != "" {
+			// e.g. the container of --mount-rootfs, removed when its session ends
+			continue
+		}
+		name := c.ID[:12]
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		records = append(records, listRecord{
+			Type:   "container",
+			Name:   name,
+			Target: c.Labels[labelTarget],
+			Image:  c.Image,
+			Status: c.Status,
+		})
+	}
+
+	volumes, err := cli.VolumeList(ctx, filters.NewArgs(filters.Arg("name", "debug-ctr-")))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes.Volumes {
+		// The name filter matches anywhere in the name
+		if !strings.HasPrefix(v.Name, "debug-ctr-") {
+			continue
+		}
+		records = append(records, listRecord{
+			Type: "volume",
+			Name: v.Name,
+		})
+	}
+	return records, nil
+}
+
+// printRecords prints records with the Go template format. A "table" prefix prints the records as aligned columns
+// under a header made of the field names, the default columns if nothing follows it.
+func printRecords(format string, records []listRecord) error {
+	table := strings.HasPrefix(format, "table")
+	if table {
+		format = strings.TrimSpace(strings.TrimPrefix(format, "table"))
+		if format == "" {
+			format = strings.TrimPrefix(defaultListFormat, "table ")
+		}
+	}
+	// Allow escaped tabs and newlines, as typed in a shell
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(format + "\n")
+	if err != nil {
+		return fmt.Errorf("invalid --format: %w", err)
+	}
+
+	if !table {
+		for _, r := range records {
+			if err := tmpl.Execute(os.Stdout, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 3, ' ', 0)
+	header := listRecord{Type: "TYPE", Name: "NAME", Target: "TARGET", Image: "IMAGE", Status: "STATUS"}
+	for _, r := range append([]listRecord{header}, records...) {
+		if err := tmpl.Execute(w, r); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
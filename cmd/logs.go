@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// logLimits bounds the logs printed for a container, like the --tail and --since options of `docker logs`.
+type logLimits struct {
+	// tail is the number of lines to show from the end of the logs, or "all".
+	tail string
+	// since is a timestamp or a duration relative to now, e.g. 10m.
+	since string
+}
+
+// validateLogTail validates the value of --tail, a number of lines or "all".
+func validateLogTail(tail string) error {
+	if tail == "all" {
+		return nil
+	}
+	if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+		return fmt.Errorf("invalid --tail %q: expected a number of lines or all", tail)
+	}
+	return nil
+}
+
+// printContainerLogs writes the stdout and stderr of a container to the host's stderr, prefixing every line with label.
+func printContainerLogs(ctx context.Context, containerID, label string, limits logLimits) error {
+	reader, err := cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       limits.tail,
+		Since:      limits.since,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	w := &prefixWriter{w: os.Stderr, prefix: []byte("[" + label + "] ")}
+	defer w.flush()
+	_, err = stdcopy.StdCopy(w, w, reader)
+	return err
+}
+
+// prefixWriter is an io.Writer that prefixes every line written to it.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := p.w.Write(append(append([]byte{}, p.prefix...), p.buf[:i+1]...)); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush writes any trailing partial line.
+func (p *prefixWriter) flush() {
+	if len(p.buf) > 0 {
+		_, _ = p.w.Write(append(append(append([]byte{}, p.prefix...), p.buf...), '\n'))
+		p.buf = nil
+	}
+}
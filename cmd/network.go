@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// copyNetworks returns the endpoint settings of the network the copy is created on and of the other networks of the
+// target to connect it to afterwards, so the copy is reachable under the same aliases as the target, plus extraAliases.
+func copyNetworks(inspect types.ContainerJSON, extraAliases []string) (*network.NetworkingConfig, map[string]*network.EndpointSettings) {
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{},
+	}
+	others := map[string]*network.EndpointSettings{}
+	if inspect.NetworkSettings == nil {
+		return networkingConfig, others
+	}
+
+	for name, endpoint := range inspect.NetworkSettings.Networks {
+		settings := &network.EndpointSettings{}
+		// Network-scoped aliases and links are only supported on user-defined networks
+		if container.NetworkMode(name).IsUserDefined() {
+			settings.Aliases = copyAliases(endpoint.Aliases, inspect.ID, extraAliases)
+			settings.Links = endpoint.Links
+			// Keep the static IPv4 and IPv6 addresses, the target isn't running so they are free
+			if endpoint.IPAMConfig != nil {
+				ipam := *endpoint.IPAMConfig
+				settings.IPAMConfig = &ipam
+			}
+		}
+		if name == string(inspect.HostConfig.NetworkMode) {
+			networkingConfig.EndpointsConfig[name] = settings
+		} else {
+			others[name] = settings
+		}
+	}
+	return networkingConfig, others
+}
+
+// copyAliases returns the target's aliases, without the short container ID Docker adds to every container, plus extraAliases.
+func copyAliases(targetAliases []string, targetID string, extraAliases []string) []string {
+	var aliases []string
+	for _, alias := range targetAliases {
+		if len(targetID) >= 12 && alias == targetID[:12] {
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	return append(aliases, extraAliases...)
+}
+
+// copyLinks converts the target's legacy links, reported as "/<container>:/<target>/<alias>", to the
+// "<container>:<alias>" format expected when creating a container.
+func copyLinks(targetLinks []string) []string {
+	links := make([]string, 0, len(targetLinks))
+	for _, link := range targetLinks {
+		name, alias, ok := strings.Cut(link, ":")
+		if !ok {
+			continue
+		}
+		links = append(links, strings.TrimPrefix(name, "/")+":"+alias[strings.LastIndex(alias, "/")+1:])
+	}
+	return links
+}
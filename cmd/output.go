@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moby/term"
+)
+
+// ANSI escape sequences used to highlight warnings and errors on a terminal.
+const (
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// Values of --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorMode is the value of --color.
+var colorMode = colorAuto
+
+// validateColorMode validates the value of --color.
+func validateColorMode() error {
+	switch colorMode {
+	case colorAuto, colorAlways, colorNever:
+		return nil
+	}
+	return fmt.Errorf("invalid --color %q (valid values: %s|%s|%s)", colorMode, colorAuto, colorAlways, colorNever)
+}
+
+// useColor reports whether the output written to f may contain escape sequences: always with --color=always, never
+// with --color=never, otherwise only on a terminal and unless NO_COLOR is set (see https://no-color.org).
+func useColor(f *os.File) bool {
+	switch colorMode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return term.IsTerminal(f.Fd())
+}
+
+// Values of --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevels are the values of --log-level.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+var (
+	// logLevel is the value of --log-level.
+	logLevel = "info"
+	// logFormat is the value of --log-format.
+	logFormat = logFormatText
+)
+
+// validateLogFlags validates the values of --log-level and --log-format.
+func validateLogFlags() error {
+	if _, ok := logLevels[logLevel]; !ok {
+		return fmt.Errorf("invalid --log-level %q (valid values: debug|info|warn|error)", logLevel)
+	}
+	if logFormat != logFormatText && logFormat != logFormatJSON {
+		return fmt.Errorf("invalid --log-format %q (valid values: %s|%s)", logFormat, logFormatText, logFormatJSON)
+	}
+	return nil
+}
+
+// setLogOutput sends the logs of --log-level and above to f, formatted according to --log-format. The logs of the
+// log package, e.g. from the dependencies, go through the same logger.
+func setLogOutput(f *os.File) {
+	level := logLevels[logLevel]
+	if logFormat == logFormatJSON {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})))
+		return
+	}
+	slog.SetDefault(slog.New(&textHandler{
+		out:   f,
+		mu:    &sync.Mutex{},
+		level: level,
+		// On a terminal the timestamps are dropped, in pipes and CI every line keeps its timestamp so it stays easy
+		// to parse
+		timestamps: !term.IsTerminal(f.Fd()),
+		color:      useColor(f),
+	}))
+}
+
+// textHandler is a slog.Handler writing a line per record meant to be read by a human: the message prefixed by the
+// level when it's not info, followed by the attributes as key=value. Warnings and errors are highlighted with color.
+type textHandler struct {
+	out        io.Writer
+	mu         *sync.Mutex
+	level      slog.Level
+	timestamps bool
+	color      bool
+	// attrs are the attributes added with WithAttrs, already formatted.
+	attrs string
+	// group is the prefix of the keys of the attributes, from WithGroup.
+	group string
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	if h.timestamps && !r.Time.IsZero() {
+		buf.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	color := ""
+	switch {
+	case r.Level >= slog.LevelError:
+		buf.WriteString("ERROR: ")
+		color = colorRed
+	case r.Level >= slog.LevelWarn:
+		buf.WriteString("WARNING: ")
+		color = colorYellow
+	case r.Level < slog.LevelInfo:
+		buf.WriteString("DEBUG: ")
+	}
+	buf.WriteString(r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&buf, h.group, a)
+		return true
+	})
+
+	line := buf.String()
+	if h.color && color != "" {
+		line = color + line + colorReset
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, line+"\n")
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		writeAttr(&buf, h.group, a)
+	}
+	h2 := *h
+	h2.attrs += buf.String()
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+// writeAttr writes a as " key=value" to buf, quoting the value if needed to keep the line parsable.
+func writeAttr(buf *bytes.Buffer, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(buf, group+a.Key+".", ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(buf, " %s%s=%s", group, a.Key, value)
+}
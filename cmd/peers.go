@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/moby/term"
+)
+
+// peer is a container related to the target, e.g. a dependency it talks to.
+type peer struct {
+	name string
+	// reasons tell how the container is related to the target.
+	reasons []string
+}
+
+// findPeers returns the containers related to target: those sharing its network namespace, those of the same
+// Compose project and those on the same user-defined networks, sorted by name.
+func findPeers(ctx context.Context, target string) ([]peer, error) {
+	inspect, err := cli.ContainerInspect(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	targetName := strings.TrimPrefix(inspect.Name, "/")
+
+	peers := map[string]*peer{}
+	add := func(name, reason string) {
+		name = strings.TrimPrefix(name, "/")
+		if name == "" || name == targetName {
+			return
+		}
+		p, ok := peers[name]
+		if !ok {
+			p = &peer{name: name}
+			peers[name] = p
+		}
+		p.reasons = append(p.reasons, reason)
+	}
+
+	// The container whose network namespace the target joined, as the infra container of a pod
+	if mode := inspect.HostConfig.NetworkMode; mode.IsContainer() {
+		if owner, err := cli.ContainerInspect(ctx, mode.ConnectedContainer()); err == nil {
+			add(owner.Name, "network namespace owner")
+		}
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	project := inspect.Config.Labels[composeProjectLabel]
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		mode := container.NetworkMode(c.HostConfig.NetworkMode)
+		if mode.IsContainer() && (mode.ConnectedContainer() == inspect.ID || mode.ConnectedContainer() == targetName) {
+			add(c.Names[0], "shares its network namespace")
+		}
+		if project != "" && c.Labels[composeProjectLabel] == project {
+			add(c.Names[0], "Compose project "+project)
+		}
+	}
+
+	if inspect.NetworkSettings != nil {
+		for name := range inspect.NetworkSettings.Networks {
+			if !container.NetworkMode(name).IsUserDefined() {
+				continue
+			}
+			// Unlike NetworkInspect, the stopped containers are listed too, they may be the ones that crashed
+			attached, err := cli.ContainerList(ctx, types.ContainerListOptions{
+				All:     true,
+				Filters: filters.NewArgs(filters.Arg("network", name)),
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range attached {
+				if len(c.Names) > 0 {
+					add(c.Names[0], "network "+name)
+				}
+			}
+		}
+	}
+
+	list := make([]peer, 0, len(peers))
+	for _, p := range peers {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list, nil
+}
+
+// selectPeer lets the user pick the container to debug among target and its peers with a numbered menu. Without a
+// terminal the peers are only listed, as there is no one to answer.
+func selectPeer(ctx context.Context, target string) (string, error) {
+	peers, err := findPeers(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	if len(peers) == 0 {
+		return "", fmt.Errorf("no container related to %s was found", target)
+	}
+
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		var names []string
+		for _, p := range peers {
+			names = append(names, p.name)
+		}
+		return "", fmt.Errorf("--debug-peers needs a terminal to pick a container, select one of the peers of %s with --target instead: %s", target, strings.Join(names, ", "))
+	}
+	return pickPeer(target, peers, os.Stdin, os.Stderr)
+}
+
+// pickPeer prints a numbered menu of target and its peers to out and returns the container whose number is read from in.
+func pickPeer(target string, peers []peer, in io.Reader, out io.Writer) (string, error) {
+	fmt.Fprintf(out, "  0) %s (target)\n", target)
+	for i, p := range peers {
+		fmt.Fprintf(out, "  %d) %s (%s)\n", i+1, p.name, strings.Join(p.reasons, ", "))
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Container to debug [0-%d]: ", len(peers))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("no container selected")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil || n < 0 || n > len(peers) {
+			continue
+		}
+		if n == 0 {
+			return target, nil
+		}
+		return peers[n-1].name, nil
+	}
+}
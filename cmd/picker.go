@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/moby/term"
+)
+
+// selectTarget lets the user pick the target container with the arrow keys in a menu of the running containers, and
+// also of the exited ones with all. The copies made by debug-ctr aren't listed. Without escape sequences, see --color,
+// the menu is numbered instead.
+func selectTarget(ctx context.Context, all bool) (string, error) {
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: all})
+	if err != nil {
+		return "", err
+	}
+	var candidates []types.Container
+	for _, c := range containers {
+		if _, ok := c.Labels[labelTarget]; ok || len(c.Names) == 0 {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no container to debug was found, start one or use --target")
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Names[0] < candidates[j].Names[0] })
+
+	// The menu is redrawn with escape sequences, without them the containers are picked by number
+	if useColor(os.Stderr) {
+		if state, err := term.SetRawTerminal(os.Stdin.Fd()); err == nil {
+			defer func() {
+				_ = term.RestoreTerminal(os.Stdin.Fd(), state)
+			}()
+			labels := make([]string, len(candidates))
+			for i, c := range candidates {
+				labels[i] = containerLabel(c)
+			}
+			i, err := arrowPick(labels, os.Stdin, os.Stderr)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(candidates[i].Names[0], "/"), nil
+		}
+	}
+	return pickTarget(candidates, os.Stdin, os.Stderr)
+}
+
+// containerLabel describes c in the menus picking a container.
+func containerLabel(c types.Container) string {
+	return fmt.Sprintf("%s (%s, %s)", strings.TrimPrefix(c.Names[0], "/"), c.Image, c.Status)
+}
+
+// arrowPick draws a menu of labels to out and returns the index of the one selected with the arrow keys, or j and k,
+// and Enter, read from in. q or Ctrl+C cancel. The terminal of in must be in raw mode, so keys are read as they are
+// pressed and lines end with \r\n.
+func arrowPick(labels []string, in io.Reader, out io.Writer) (int, error) {
+	fmt.Fprint(out, "Container to debug (up/down to move, Enter to select, q to cancel):\r\n")
+	selected := 0
+	draw := func(redraw bool) {
+		if redraw {
+			// Back to the first entry of the menu
+			fmt.Fprintf(out, "\x1b[%dA", len(labels))
+		}
+		for i, label := range labels {
+			marker := "  "
+			if i == selected {
+				marker = "> "
+			}
+			fmt.Fprintf(out, "\r\x1b[2K%s%s\r\n", marker, label)
+		}
+	}
+	draw(false)
+
+	r := bufio.NewReader(in)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return 0, fmt.Errorf("no container selected")
+			}
+			return 0, err
+		}
+		move := 0
+		switch b {
+		case '\r', '\n':
+			return selected, nil
+		case 'q', 0x03, 0x04:
+			return 0, fmt.Errorf("no container selected")
+		case 'k':
+			move = -1
+		case 'j':
+			move = 1
+		case 0x1b:
+			// The arrow keys send ESC [ A to D, or ESC O A to D in the application cursor mode
+			if prefix, err := r.ReadByte(); err != nil || (prefix != '[' && prefix != 'O') {
+				continue
+			}
+			switch key, _ := r.ReadByte(); key {
+			case 'A':
+				move = -1
+			case 'B':
+				move = 1
+			}
+		}
+		if next := selected + move; move != 0 && next >= 0 && next < len(labels) {
+			selected = next
+			draw(true)
+		}
+	}
+}
+
+// pickTarget prints a numbered menu of containers to out and returns the name of the one whose number is read from in.
+func pickTarget(containers []types.Container, in io.Reader, out io.Writer) (string, error) {
+	for i, c := range containers {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, containerLabel(c))
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Container to debug [1-%d]: ", len(containers))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("no container selected")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil || n < 1 || n > len(containers) {
+			continue
+		}
+		return strings.TrimPrefix(containers[n-1].Names[0], "/"), nil
+	}
+}
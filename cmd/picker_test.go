@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestArrowPick(t *testing.T) {
+	labels := []string{"api", "db", "web"}
+	tests := []struct {
+		name    string
+		keys    string
+		want    int
+		wantErr bool
+	}{
+		{name: "enter", keys: "\r", want: 0},
+		{name: "down", keys: "\x1b[B\r", want: 1},
+		{name: "down and up", keys: "\x1b[B\x1b[B\x1b[A\r", want: 1},
+		{name: "application cursor mode", keys: "\x1bOB\x1bOB\r", want: 2},
+		{name: "past the last", keys: "\x1b[B\x1b[B\x1b[B\x1b[B\r", want: 2},
+		{name: "above the first", keys: "\x1b[A\r", want: 0},
+		{name: "j and k", keys: "jjk\n", want: 1},
+		{name: "other keys", keys: "x\x1b[C\x1b[1;5B\r", want: 0},
+		{name: "q", keys: "jq", wantErr: true},
+		{name: "ctrl+c", keys: "\x03", wantErr: true},
+		{name: "end of input", keys: "j", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := arrowPick(labels, strings.NewReader(tt.keys), io.Discard)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: arrowPick returned %v, want an error: %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: arrowPick selected %s, want %s", tt.name, labels[got], labels[tt.want])
+		}
+	}
+}
+
+func TestArrowPickRedraw(t *testing.T) {
+	var out strings.Builder
+	if _, err := arrowPick([]string{"api", "db"}, strings.NewReader("\x1b[B\r"), &out); err != nil {
+		t.Fatal(err)
+	}
+	// Drawn once, then moved back up over the 2 entries and redrawn with the second one selected
+	want := "\r\x1b[2K> api\r\n\r\x1b[2K  db\r\n\x1b[2A\r\x1b[2K  api\r\n\r\x1b[2K> db\r\n"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("arrowPick drew %q, want it to end with %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// checkLocalPID fails unless pid is a process of the host running debug-ctr, which must also run the Docker daemon:
+// the PIDs of a remote daemon or of the VM of Docker Desktop aren't the ones the user sees.
+func checkLocalPID(pid int) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid --pid %d", pid)
+	}
+	if sshHost != "" {
+		return fmt.Errorf("--pid can't be used with the ssh daemon host %s, the process must run on the daemon's host", sshHost)
+	}
+	u, err := client.ParseHostURL(cli.DaemonHost())
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "unix" {
+		return fmt.Errorf("--pid can only be used with a local Docker daemon, not %s", cli.DaemonHost())
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(u.Path, home+"/") {
+		// Docker Desktop and colima run the daemon in a VM
+		return fmt.Errorf("--pid can't be used with %s, the Docker daemon runs in a VM and can't see the host's processes", cli.DaemonHost())
+	}
+	if _, err := os.Stat("/proc/" + strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("no process with PID %d on this host", pid)
+	}
+	return nil
+}
+
+// createPIDContainer runs a privileged container of debugImage in the host's PID namespace, from which the
+// namespaces of the process pid can be entered with nsenter. It returns the ID of the container, the caller must
+// remove it.
+func createPIDContainer(ctx context.Context, debugImage string, pid int, ociRuntime string) (string, error) {
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      debugImage,
+		Entrypoint: []string{"/bin/sh", "-c", "tail -f /dev/null"}, // keep container running in the background
+	}, &container.HostConfig{
+		// Entering the namespaces of another process needs CAP_SYS_ADMIN and CAP_SYS_PTRACE
+		Privileged: true,
+		PidMode:    "host",
+		Runtime:    ociRuntime,
+	}, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		removeDebugContainer(resp.ID)
+		return "", err
+	}
+
+	if _, err := execOutput(ctx, resp.ID, []string{"/bin/sh", "-c", "command -v nsenter"}); err != nil {
+		removeDebugContainer(resp.ID)
+		return "", fmt.Errorf("the debug image %s must include nsenter to debug a process by PID: %w", debugImage, err)
+	}
+	slog.Info(fmt.Sprintf("The root filesystem of process %d is available at /proc/%d/root", pid, pid))
+	return resp.ID, nil
+}
+
+// pidExecArgs returns the arguments to run command in the network, UTS and IPC namespaces of the process pid. The
+// mount namespace is not entered so the tools of the debug image stay available.
+func pidExecArgs(pid int, command []string) []string {
+	return append([]string{"nsenter", "-t", strconv.Itoa(pid), "-n", "-u", "-i", "--"}, command...)
+}
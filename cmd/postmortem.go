@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// commitTarget commits the filesystem of the exited targetContainer to an untagged image, so the copy starts from the
+// exact state the target was in when it died instead of from its original image. The volumes of the target aren't
+// part of the image, the copy mounts them with --volumes-from, see copyOptions.targetVolumes. The caller must remove
+// the image with removeCommittedImage.
+func commitTarget(ctx context.Context, targetContainer string) (string, error) {
+	inspect, err := cli.ContainerInspect(ctx, targetContainer)
+	if err != nil {
+		return "", err
+	}
+	if inspect.State.Running {
+		return "", fmt.Errorf("%s is running, --postmortem is for containers that have exited, copy it without --postmortem instead", targetContainer)
+	}
+
+	slog.Info(fmt.Sprintf("Committing the final filesystem of %s", targetContainer))
+	resp, err := cli.ContainerCommit(ctx, targetContainer, types.ContainerCommitOptions{
+		Comment: "debug-ctr --postmortem of " + targetContainer,
+		Config: &container.Config{
+			Labels: map[string]string{labelTarget: targetContainer},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("committing the filesystem of %s: %w", targetContainer, err)
+	}
+	return resp.ID, nil
+}
+
+// removeCommittedImage removes an image created by commitTarget, once no container uses it anymore.
+func removeCommittedImage(image string) {
+	if _, err := cli.ImageRemove(context.Background(), image, types.ImageRemoveOptions{
+		PruneChildren: true,
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("could not remove the image %s of the target's final filesystem", image), "error", err)
+	}
+}
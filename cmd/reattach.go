@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var reattachCmd = &cobra.Command{
+	Use:   "reattach <copy-container>",
+	Short: "Print the command to shell into an existing copy container",
+	Long:  `Looks up the labels debug-ctr sets on a copy container and prints (or launches) the docker exec command to get back into it.`,
+	Example: `
+debug-ctr reattach my-distroless-copy
+debug-ctr reattach my-distroless-copy --attach
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		attach, terminal := terminalFlags(cmd.Flags())
+		copyContainer := args[0]
+
+		ctx := context.Background()
+
+		inspect, err := cli.ContainerInspect(ctx, copyContainer)
+		if err != nil {
+			return err
+		}
+
+		mountPath, ok := inspect.Config.Labels[labelMountPath]
+		if !ok || inspect.Config.Labels[labelRole] != "" {
+			return fmt.Errorf("container %s is not a copy container created by debug-ctr", copyContainer)
+		}
+		shell, ok := inspect.Config.Labels[labelShell]
+		if !ok {
+			shell = mountPath + "/sh"
+		}
+		if !inspect.State.Running {
+			return fmt.Errorf("copy container %s is not running", copyContainer)
+		}
+
+		dockerExecCmd := copyExecCommand(copyContainer, mountPath, shell)
+
+		printDebugCommand(dockerExecCmd)
+
+		if attach {
+			if err := attachShell(ctx, terminal, dockerExecCmd, copyContainer, copyExecArgs(mountPath, shell, "exec "+shell)); err != nil {
+				// The debug container is ready, the printed command can still be run manually
+				slog.Warn("could not open a terminal, run the command above instead", "error", err)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reattachCmd)
+
+	addTerminalFlags(reattachCmd.Flags())
+}
@@ -1,9 +1,27 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/docker/cli/cli/connhelper"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	cli     *client.Client
+	cfgFile string
+	// sshHost is the ssh:// daemon host the Docker API is tunnelled to, if any.
+	sshHost string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -16,6 +34,25 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		if err := validateColorMode(); err != nil {
+			return err
+		}
+		if err := validateLogFlags(); err != nil {
+			return err
+		}
+		setLogOutput(os.Stderr)
+
+		var err error
+		if cli, err = newDockerClient(cmd); err != nil {
+			return err
+		}
+		warnOldAPIVersion(context.Background())
+		return nil
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -26,18 +63,163 @@ to quickly create a Cobra application.`,
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
+	cobra.OnInitialize(initConfig)
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.debug-ctr.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.debug-ctr.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "(optional) The profile of the config file whose settings to use, e.g. for a kind of target image")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", colorAuto, "(optional) When to use colors and terminal escape sequences in the output (auto|always|never), auto disables them if NO_COLOR is set")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", logLevel, "(optional) The minimum level of the logs to print (debug|info|warn|error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "(optional) The format of the logs (text|json), json writes an object per line")
+	rootCmd.PersistentFlags().StringP("docker-host", "H", "", "(optional) The Docker daemon socket to connect to (default is $DOCKER_HOST)")
+	rootCmd.PersistentFlags().Bool("tls", false, "(optional) Use TLS to connect to the Docker daemon; implied by --tls-verify")
+	rootCmd.PersistentFlags().Bool("tls-verify", false, "(optional) Use TLS and verify the Docker daemon's certificate (default is $DOCKER_TLS_VERIFY)")
+	rootCmd.PersistentFlags().String("cert-path", "", "(optional) The directory with the TLS ca.pem, cert.pem and key.pem files (default is $DOCKER_CERT_PATH or ~/.docker)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// initConfig reads in config file if set.
+func initConfig() {
+	if cfgFile != "" {
+		// Use config file from the flag.
+		viper.SetConfigFile(cfgFile)
+	} else {
+		// Find home directory.
+		home, err := os.UserHomeDir()
+		cobra.CheckErr(err)
+
+		// Search config in home directory with name ".debug-ctr" (without extension).
+		viper.AddConfigPath(home)
+		viper.SetConfigType("yaml")
+		viper.SetConfigName(".debug-ctr")
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		// A missing default config file is fine, a broken or explicitly requested one is not
+		if cfgFile != "" || !errors.As(err, &notFound) {
+			cobra.CheckErr(fmt.Errorf("reading config file: %w", err))
+		}
+	}
+}
+
+// applyConfig sets the flags of cmd that were not given on the command line to the value of the key with the same
+// name of the --profile, if any, and then of the config file, so command-line flags always take precedence over the
+// profile, which takes precedence over the config file.
+func applyConfig(cmd *cobra.Command) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	if profile != "" {
+		settings := viper.Sub("profiles." + profile)
+		if settings == nil {
+			return fmt.Errorf("unknown --profile %q, profiles are defined under the profiles key of the config file", profile)
+		}
+		if err := applySettings(cmd, settings); err != nil {
+			return fmt.Errorf("applying profile %s: %w", profile, err)
+		}
+	}
+	return applySettings(cmd, viper.GetViper())
+}
+
+// applySettings sets the flags of cmd that are not set yet to the value of the key of settings with the same name.
+func applySettings(cmd *cobra.Command, settings *viper.Viper) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || !settings.IsSet(f.Name) {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			err = sv.Replace(settings.GetStringSlice(f.Name))
+			f.Changed = true
+			return
+		}
+		err = cmd.Flags().Set(f.Name, settings.GetString(f.Name))
+	})
+	return err
+}
+
+// newDockerClient creates the Docker client from the environment, overridden by any of the connection flags that were set.
+func newDockerClient(cmd *cobra.Command) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+
+	flags := cmd.Flags()
+	host, _ := flags.GetString("docker-host")
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+
+	if flags.Changed("tls") || flags.Changed("tls-verify") || flags.Changed("cert-path") {
+		useTLS, _ := flags.GetBool("tls")
+		tlsVerify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+		if flags.Changed("tls-verify") {
+			tlsVerify, _ = flags.GetBool("tls-verify")
+		}
+		certPath, _ := flags.GetString("cert-path")
+		if certPath == "" {
+			certPath = os.Getenv("DOCKER_CERT_PATH")
+		}
+		if certPath == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			certPath = filepath.Join(home, ".docker")
+		}
+
+		if useTLS || tlsVerify {
+			options := tlsconfig.Options{
+				InsecureSkipVerify: !tlsVerify,
+			}
+			// Only present a client certificate if one is available, as --tls alone doesn't require it
+			if _, err := os.Stat(filepath.Join(certPath, "cert.pem")); err == nil || tlsVerify {
+				options.CAFile = filepath.Join(certPath, "ca.pem")
+				options.CertFile = filepath.Join(certPath, "cert.pem")
+				options.KeyFile = filepath.Join(certPath, "key.pem")
+			}
+			tlsc, err := tlsconfig.Client(options)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, client.WithHTTPClient(&http.Client{
+				Transport:     &http.Transport{TLSClientConfig: tlsc},
+				CheckRedirect: client.CheckRedirect,
+			}))
+			// The new transport has to be configured for the host again
+			if host == "" {
+				host = client.DefaultDockerHost
+			}
+		}
+	}
+
+	if strings.HasPrefix(host, "ssh://") {
+		// The Docker API is tunnelled through `docker system dial-stdio` run over ssh on the remote host
+		helper, err := connhelper.GetConnectionHelper(host)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			client.WithHTTPClient(&http.Client{
+				Transport:     &http.Transport{DialContext: helper.Dialer},
+				CheckRedirect: client.CheckRedirect,
+			}),
+			client.WithDialContext(helper.Dialer),
+		)
+		sshHost, host = host, helper.Host
+	}
+
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+
+	return client.NewClientWithOpts(opts...)
+}
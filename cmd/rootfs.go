@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// rootfsMountPath is where the target's root filesystem is available in the container created with --mount-rootfs.
+const rootfsMountPath = "/rootfs"
+
+// createRootfsContainer runs a container of debugImage with the root filesystem of the running targetContainer
+// available at rootfsMountPath, leaving the target untouched. It returns the ID of the container, the caller must
+// remove it.
+//
+// With the overlay storage drivers the target's merged directory on the daemon's host is bind-mounted read-only.
+// Otherwise the container joins the target's PID namespace and rootfsMountPath links to /proc/1/root, which isn't
+// read-only.
+func createRootfsContainer(ctx context.Context, debugImage, targetContainer, ociRuntime string) (string, error) {
+	inspect, err := cli.ContainerInspect(ctx, targetContainer)
+	if err != nil {
+		return "", err
+	}
+	if !inspect.State.Running {
+		// The merged directory is only mounted, and /proc/1/root only exists, while the target runs
+		return "", fmt.Errorf("the root filesystem of %s can't be mounted as it is not running, use the copy command instead", targetContainer)
+	}
+
+	config := &container.Config{
+		Image:      debugImage,
+		Entrypoint: []string{"/bin/sh", "-c", "tail -f /dev/null"}, // keep container running in the background
+		Labels: map[string]string{
+			labelTarget: targetContainer,
+			// Not a copy, it has no tools for reattach and isn't listed
+			labelRole: roleRootfs,
+		},
+	}
+	hostConfig := &container.HostConfig{
+		Runtime: ociRuntime,
+	}
+	if mergedDir := inspect.GraphDriver.Data["MergedDir"]; mergedDir != "" {
+		hostConfig.Binds = []string{mergedDir + ":" + rootfsMountPath + ":ro"}
+	} else {
+		slog.Warn(fmt.Sprintf("the %s storage driver doesn't expose the root filesystem of %s, it is available through its PID namespace at %s instead, which is not read-only", inspect.GraphDriver.Name, targetContainer, rootfsMountPath))
+		hostConfig.PidMode = container.PidMode("container:" + targetContainer)
+		config.Entrypoint = []string{"/bin/sh", "-c", "ln -s /proc/1/root " + rootfsMountPath + " && exec tail -f /dev/null"}
+	}
+
+	resp, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		removeDebugContainer(resp.ID)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// removeDebugContainer force-removes a container created by debug-ctr, e.g. with --mount-rootfs, --pid or --postmortem.
+func removeDebugContainer(containerID string) {
+	if err := cli.ContainerRemove(context.Background(), containerID, types.ContainerRemoveOptions{
+		Force: true,
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("could not remove debug container %s", containerID), "error", err)
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// dockerRunCommand renders the `docker run` command that creates a container named name with the given configuration.
+// Only the settings debug-ctr sets on copy containers are rendered.
+func dockerRunCommand(name string, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig) string {
+	args := []string{"docker", "run", "-d"}
+	add := func(flag, value string) {
+		args = append(args, flag+"="+shellQuote(value))
+	}
+
+	if name != "" {
+		add("--name", name)
+	}
+	if config.Hostname != "" {
+		add("--hostname", config.Hostname)
+	}
+	if config.Domainname != "" {
+		add("--domainname", config.Domainname)
+	}
+	if config.MacAddress != "" {
+		add("--mac-address", config.MacAddress)
+	}
+	if config.User != "" {
+		add("--user", config.User)
+	}
+	if config.WorkingDir != "" {
+		add("--workdir", config.WorkingDir)
+	}
+	for _, env := range config.Env {
+		add("--env", env)
+	}
+	labels := make([]string, 0, len(config.Labels))
+	for k, v := range config.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		add("--label", label)
+	}
+	if config.StopSignal != "" {
+		add("--stop-signal", config.StopSignal)
+	}
+	if config.StopTimeout != nil {
+		add("--stop-timeout", strconv.Itoa(*config.StopTimeout))
+	}
+
+	for _, from := range hostConfig.VolumesFrom {
+		add("--volumes-from", from)
+	}
+	for _, bind := range hostConfig.Binds {
+		add("--volume", bind)
+	}
+	for port, bindings := range hostConfig.PortBindings {
+		for _, b := range bindings {
+			hostPort := b.HostPort
+			if b.HostIP != "" {
+				hostPort = b.HostIP + ":" + hostPort
+			}
+			add("--publish", hostPort+":"+string(port))
+		}
+	}
+	if hostConfig.NetworkMode != "" {
+		add("--network", string(hostConfig.NetworkMode))
+	}
+	if endpoint, ok := networkingConfig.EndpointsConfig[string(hostConfig.NetworkMode)]; ok {
+		for _, alias := range endpoint.Aliases {
+			add("--network-alias", alias)
+		}
+		for _, link := range endpoint.Links {
+			add("--link", link)
+		}
+		if endpoint.IPAMConfig != nil {
+			if endpoint.IPAMConfig.IPv4Address != "" {
+				add("--ip", endpoint.IPAMConfig.IPv4Address)
+			}
+			if endpoint.IPAMConfig.IPv6Address != "" {
+				add("--ip6", endpoint.IPAMConfig.IPv6Address)
+			}
+		}
+	}
+	for _, host := range hostConfig.ExtraHosts {
+		add("--add-host", host)
+	}
+	for _, link := range hostConfig.Links {
+		add("--link", link)
+	}
+	if hostConfig.PidMode != "" {
+		add("--pid", string(hostConfig.PidMode))
+	}
+	if hostConfig.UsernsMode != "" {
+		add("--userns", string(hostConfig.UsernsMode))
+	}
+	if hostConfig.UTSMode != "" {
+		add("--uts", string(hostConfig.UTSMode))
+	}
+	if hostConfig.Init != nil {
+		args = append(args, "--init="+strconv.FormatBool(*hostConfig.Init))
+	}
+	if hostConfig.CgroupParent != "" {
+		add("--cgroup-parent", hostConfig.CgroupParent)
+	}
+	if hostConfig.ShmSize > 0 {
+		add("--shm-size", strconv.FormatInt(hostConfig.ShmSize, 10))
+	}
+	if hostConfig.OomKillDisable != nil && *hostConfig.OomKillDisable {
+		args = append(args, "--oom-kill-disable")
+	}
+	if hostConfig.OomScoreAdj != 0 {
+		add("--oom-score-adj", strconv.Itoa(hostConfig.OomScoreAdj))
+	}
+	if hostConfig.ReadonlyRootfs {
+		args = append(args, "--read-only")
+	}
+	if hostConfig.Runtime != "" {
+		add("--runtime", hostConfig.Runtime)
+	}
+	if hostConfig.LogConfig.Type != "" {
+		add("--log-driver", hostConfig.LogConfig.Type)
+		logOpts := make([]string, 0, len(hostConfig.LogConfig.Config))
+		for k, v := range hostConfig.LogConfig.Config {
+			logOpts = append(logOpts, k+"="+v)
+		}
+		sort.Strings(logOpts)
+		for _, opt := range logOpts {
+			add("--log-opt", opt)
+		}
+	}
+	for _, ulimit := range hostConfig.Ulimits {
+		add("--ulimit", ulimit.String())
+	}
+	if hostConfig.BlkioWeight != 0 {
+		add("--blkio-weight", strconv.Itoa(int(hostConfig.BlkioWeight)))
+	}
+	for _, device := range hostConfig.BlkioWeightDevice {
+		add("--blkio-weight-device", device.String())
+	}
+	for _, device := range hostConfig.BlkioDeviceReadBps {
+		add("--device-read-bps", device.String())
+	}
+	for _, device := range hostConfig.BlkioDeviceWriteBps {
+		add("--device-write-bps", device.String())
+	}
+	for _, device := range hostConfig.BlkioDeviceReadIOps {
+		add("--device-read-iops", device.String())
+	}
+	for _, device := range hostConfig.BlkioDeviceWriteIOps {
+		add("--device-write-iops", device.String())
+	}
+	for _, device := range hostConfig.Devices {
+		add("--device", device.PathOnHost+":"+device.PathInContainer+":"+device.CgroupPermissions)
+	}
+	for _, request := range hostConfig.DeviceRequests {
+		add("--gpus", gpusFlagValue(request))
+	}
+
+	// docker run only takes the first element of the entrypoint, the rest goes before the command
+	cmd := []string(config.Cmd)
+	if len(config.Entrypoint) > 0 {
+		add("--entrypoint", config.Entrypoint[0])
+		cmd = append(append([]string{}, config.Entrypoint[1:]...), cmd...)
+	}
+
+	args = append(args, shellQuote(config.Image))
+	for _, arg := range cmd {
+		args = append(args, shellQuote(arg))
+	}
+	return strings.Join(args, " \\\n  ")
+}
+
+// gpusFlagValue renders a device request as the value of `docker run --gpus`.
+func gpusFlagValue(request container.DeviceRequest) string {
+	switch {
+	case len(request.DeviceIDs) > 0:
+		return `"device=` + strings.Join(request.DeviceIDs, ",") + `"`
+	case request.Count < 0:
+		return "all"
+	default:
+		return fmt.Sprintf("%d", request.Count)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/spf13/cobra"
+)
+
+// sessionSpecVersion is the version of the format of the files written with --save-session. It's increased on
+// incompatible changes, so older versions of debug-ctr refuse the files they can't replay faithfully.
+const sessionSpecVersion = 1
+
+// sessionSpec is the resolved configuration of a copy container, written with --save-session.
+type sessionSpec struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Target is a snapshot of the target container when the copy was created.
+	Target types.ContainerJSON `json:"target"`
+	// Images are the debug images whose tools are copied into ToolsVolume, in order, empty with HostTools.
+	Images      []string `json:"images,omitempty"`
+	Overwrite   bool     `json:"overwrite,omitempty"`
+	ToolsVolume string   `json:"toolsVolume,omitempty"`
+	HostTools   string   `json:"hostTools,omitempty"`
+	// EphemeralTools tells ToolsVolume is a tmpfs volume, which must be populated again whenever the copy starts.
+	EphemeralTools bool `json:"ephemeralTools,omitempty"`
+	// Name is the name of the copy container.
+	Name             string                               `json:"name"`
+	Config           *container.Config                    `json:"config"`
+	HostConfig       *container.HostConfig                `json:"hostConfig"`
+	NetworkingConfig *network.NetworkingConfig            `json:"networkingConfig,omitempty"`
+	OtherNetworks    map[string]*network.EndpointSettings `json:"otherNetworks,omitempty"`
+}
+
+// newSessionSpec returns the spec of the copy of the target described by inspect, created with the given configuration.
+func newSessionSpec(inspect types.ContainerJSON, opts copyOptions, tools string, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, otherNetworks map[string]*network.EndpointSettings) sessionSpec {
+	spec := sessionSpec{
+		Version:          sessionSpecVersion,
+		Created:          time.Now().UTC(),
+		Target:           inspect,
+		Name:             opts.copyContainerName,
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: networkingConfig,
+		OtherNetworks:    otherNetworks,
+	}
+	if opts.hostTools != "" {
+		spec.HostTools = opts.hostTools
+	} else {
+		spec.Images = opts.debugImages
+		spec.Overwrite = opts.overwrite
+		spec.ToolsVolume = tools
+		spec.EphemeralTools = opts.ephemeralTools
+	}
+	return spec
+}
+
+// writeSessionSpec writes spec as indented JSON to the file at path.
+func writeSessionSpec(path string, spec sessionSpec) error {
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// readSessionSpec reads the spec written with --save-session to the file at path.
+func readSessionSpec(path string) (sessionSpec, error) {
+	var spec sessionSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("%s is not a debug session: %w", path, err)
+	}
+	if spec.Version < 1 || spec.Version > sessionSpecVersion {
+		return spec, fmt.Errorf("%s has version %d of the debug session format, this version of debug-ctr supports up to %d", path, spec.Version, sessionSpecVersion)
+	}
+	if spec.Config == nil || spec.HostConfig == nil {
+		return spec, fmt.Errorf("%s is not a debug session: the configuration of the copy is missing", path)
+	}
+	return spec, nil
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session-file>",
+	Short: "Recreate a copy container from a debug session saved with --save-session",
+	Long: `Recreates the copy container described by a file written with --save-session, with the same configuration and
+tools, e.g. to share a debugging environment or get back to it after cleaning up.`,
+	Example: `
+debug-ctr replay my-distroless-copy.json
+debug-ctr replay my-distroless-copy.json --to=my-distroless-copy-2
+debug-ctr replay my-distroless-copy.json --replace --attach
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		attach, terminal := terminalFlags(cmd.Flags())
+		name, _ := cmd.Flags().GetString("to")
+		replace, _ := cmd.Flags().GetBool("replace")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		spec, err := readSessionSpec(args[0])
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = spec.Name
+		}
+
+		ctx := context.Background()
+		if err := ensureCopyNameAvailable(ctx, name, replace, yes); err != nil {
+			return err
+		}
+		if err := replaySession(ctx, spec, name); err != nil {
+			return withExitCode(exitCodeCopyFailed, err)
+		}
+
+		dockerExecCmd := copyExecCommand(name, debuggerMountPath, debuggerMountPath+"/sh")
+		emitEvent(event{Type: eventExecReady, Container: name, Command: dockerExecCmd})
+		printDebugCommand(dockerExecCmd)
+		if attach {
+			shell := debuggerMountPath + "/sh"
+			if err := attachShell(ctx, terminal, dockerExecCmd, name, copyExecArgs(debuggerMountPath, shell, "exec "+shell)); err != nil {
+				// The debug container is ready, the printed command can still be run manually
+				slog.Warn("could not open a terminal, run the command above instead", "error", err)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	addTerminalFlags(replayCmd.Flags())
+	replayCmd.Flags().String("to", "", "(optional) The name of the copy container, instead of the saved one")
+	replayCmd.Flags().Bool("replace", false, "(optional) Remove an existing container with the name of the copy container before creating it")
+	replayCmd.Flags().BoolP("yes", "y", false, "(optional) Confirm --replace without prompting")
+}
+
+// replaySession pulls the debug images and populates the tools volume of spec, then creates and starts the copy
+// container named name.
+func replaySession(ctx context.Context, spec sessionSpec, name string) error {
+	if spec.HostTools != "" {
+		if _, err := os.Stat(spec.HostTools); err != nil {
+			return fmt.Errorf("the host tools of the session are not available: %w", err)
+		}
+	} else {
+		for _, image := range spec.Images {
+			if err := pullImage(ctx, image); err != nil {
+				return withExitCode(exitCodePullFailed, err)
+			}
+		}
+		if spec.EphemeralTools {
+			release, err := holdEphemeralVolume(ctx, spec.Images[0], spec.ToolsVolume)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+		if err := populateTools(ctx, copyOptions{debugImages: spec.Images, overwrite: spec.Overwrite}, spec.ToolsVolume); err != nil {
+			return err
+		}
+	}
+
+	if mode := spec.HostConfig.NetworkMode; mode.IsContainer() {
+		slog.Info(fmt.Sprintf("The copy shares the namespaces of %s, which must be running", strings.TrimPrefix(string(mode), "container:")))
+	}
+	return startCopyContainer(ctx, name, spec.Config, spec.HostConfig, spec.NetworkingConfig, spec.OtherNetworks)
+}
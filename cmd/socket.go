@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// defaultDockerSocket is where the Docker daemon listens by default, and where addmount expects the socket.
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// daemonSocketPath returns the path of the Docker daemon socket on the daemon's host, to be bind-mounted into the
+// addmount container.
+func daemonSocketPath() (string, error) {
+	if sshHost != "" {
+		// The API is tunnelled over ssh, the client doesn't know where the remote daemon listens
+		return "", fmt.Errorf("adding a mount is not supported for the ssh daemon host %s, as the socket of the remote daemon can't be determined, use the copy command instead", sshHost)
+	}
+
+	host := cli.DaemonHost()
+	u, err := client.ParseHostURL(host)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		// Docker Desktop and colima forward a socket in the user's home to a daemon running in a VM,
+		// where it listens on the default path
+		if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(u.Path, home+string(filepath.Separator)) {
+			return defaultDockerSocket, nil
+		}
+		// e.g. rootless Docker listening on $XDG_RUNTIME_DIR/docker.sock
+		return u.Path, nil
+	case "npipe":
+		// Docker Desktop on Windows runs the daemon in a Linux VM
+		return defaultDockerSocket, nil
+	default:
+		return "", fmt.Errorf("adding a mount needs access to the Docker daemon socket, which can't be determined for the %s daemon host %s, use the copy command instead", u.Scheme, host)
+	}
+}
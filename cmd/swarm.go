@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// Labels set by Docker Swarm on the containers of a service task.
+const (
+	swarmServiceNameLabel = "com.docker.swarm.service.name"
+	swarmTaskNameLabel    = "com.docker.swarm.task.name"
+)
+
+// resolveSwarmService returns the ID of the container of a running task of a Docker Swarm service. slot selects a
+// replica and is required if the service has several tasks on this node. As debug-ctr works with the containers of the
+// daemon it talks to, the task must run on that daemon's node.
+func resolveSwarmService(ctx context.Context, service string, slot int) (string, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.Swarm.LocalNodeState != swarm.LocalNodeStateActive {
+		return "", fmt.Errorf("the Docker daemon is not part of a Swarm, --service can't be used")
+	}
+	if !info.Swarm.ControlAvailable {
+		// Only the managers know the tasks of the other nodes
+		return resolveLocalSwarmService(ctx, service, slot)
+	}
+
+	tasks, err := cli.TaskList(ctx, types.TaskListOptions{Filters: filters.NewArgs(
+		filters.Arg("service", service),
+		filters.Arg("desired-state", "running"),
+	)})
+	if err != nil {
+		return "", err
+	}
+	var running []swarm.Task
+	for _, t := range tasks {
+		if t.Status.State == swarm.TaskStateRunning && t.Status.ContainerStatus != nil && t.Status.ContainerStatus.ContainerID != "" {
+			running = append(running, t)
+		}
+	}
+	if len(running) == 0 {
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("no running task found for Swarm service %s", service))
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Slot < running[j].Slot })
+
+	task, err := selectSwarmTask(ctx, service, slot, running, info.Swarm.NodeID)
+	if err != nil {
+		return "", err
+	}
+	if task.NodeID != info.Swarm.NodeID {
+		node := task.NodeID
+		if n, _, err := cli.NodeInspectWithRaw(ctx, task.NodeID); err == nil {
+			node = n.Description.Hostname
+		}
+		return "", fmt.Errorf("the task of Swarm service %s runs on node %s, run debug-ctr on that node or point it to that node's daemon, e.g. with --docker-host=ssh://user@%s", service, node, node)
+	}
+	return task.Status.ContainerStatus.ContainerID, nil
+}
+
+// selectSwarmTask returns the task of tasks in slot if set. Otherwise the service must have a single task, or a single
+// one on the local node, e.g. for a global service.
+func selectSwarmTask(ctx context.Context, service string, slot int, tasks []swarm.Task, localNode string) (swarm.Task, error) {
+	var replicas []string
+	var local []swarm.Task
+	for _, t := range tasks {
+		if slot > 0 && t.Slot == slot {
+			return t, nil
+		}
+		replicas = append(replicas, fmt.Sprintf("slot %d on node %s", t.Slot, t.NodeID))
+		if t.NodeID == localNode {
+			local = append(local, t)
+		}
+	}
+	if slot > 0 {
+		return swarm.Task{}, withExitCode(exitCodeTargetNotFound, fmt.Errorf("Swarm service %s has no running task in slot %d, it has %s", service, slot, strings.Join(replicas, ", ")))
+	}
+	if len(tasks) == 1 {
+		return tasks[0], nil
+	}
+	if len(local) == 1 {
+		return local[0], nil
+	}
+	return swarm.Task{}, fmt.Errorf("Swarm service %s has several tasks, select one with --service-slot: %s", service, strings.Join(replicas, ", "))
+}
+
+// resolveLocalSwarmService is resolveSwarmService for the worker nodes, which only see their own tasks, from the
+// labels of their containers.
+func resolveLocalSwarmService(ctx context.Context, service string, slot int) (string, error) {
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", swarmServiceNameLabel+"="+service)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("no running task of Swarm service %s on this node, a worker only sees its own tasks: run debug-ctr on the node of the task or against a manager", service))
+	}
+
+	var replicas []string
+	for _, c := range containers {
+		// Task names are <service>.<slot>.<task ID>, with the node ID instead of the slot for global services
+		taskSlot, _, _ := strings.Cut(strings.TrimPrefix(c.Labels[swarmTaskNameLabel], service+"."), ".")
+		if slot > 0 && taskSlot == strconv.Itoa(slot) {
+			return c.ID, nil
+		}
+		replicas = append(replicas, "slot "+taskSlot)
+	}
+	if slot > 0 {
+		return "", withExitCode(exitCodeTargetNotFound, fmt.Errorf("Swarm service %s has no running task in slot %d on this node, it has %s", service, slot, strings.Join(replicas, ", ")))
+	}
+	if len(containers) > 1 {
+		return "", fmt.Errorf("Swarm service %s has several tasks on this node, select one with --service-slot: %s", service, strings.Join(replicas, ", "))
+	}
+	return containers[0].ID, nil
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// toolsStagingDir is the directory of the toolkit container where the resolved tools are staged with --follow-symlinks.
+const toolsStagingDir = "/.debug-ctr"
+
+// resolveToolsScript copies the real file behind every entry of the directory given as first argument into
+// $toolsStagingDir/bin, resolving the whole symlink chain, and the loader and libraries they need (as reported by ldd)
+// into $toolsStagingDir/libs, keeping their absolute path. It prints the libraries that were staged.
+//
+// Each real file is only copied once, the entries resolving to a file already staged, such as the applets of a
+// multi-call binary like busybox, are hard links to it. $toolsStagingDir/real maps the real files to their copy.
+const resolveToolsScript = `set -e
+src="$1"; dst="` + toolsStagingDir + `"
+rm -rf "$dst"; mkdir -p "$dst/bin" "$dst/libs" "$dst/real"
+for f in "$src"/*; do
+  real=$(readlink -f "$f") || continue
+  [ -f "$real" ] || continue
+  if [ -e "$dst/real$real" ]; then
+    ln "$dst/real$real" "$dst/bin/$(basename "$f")"
+    continue
+  fi
+  cp "$real" "$dst/bin/$(basename "$f")"
+  mkdir -p "$dst/real$(dirname "$real")"
+  ln "$dst/bin/$(basename "$f")" "$dst/real$real"
+  command -v ldd >/dev/null 2>&1 || continue
+  ldd "$real" 2>/dev/null | while read -r a b c rest; do
+    lib=""
+    case "$a" in
+      /*) lib="$a" ;;
+      *) [ "$b" = "=>" ] && lib="$c" ;;
+    esac
+    case "$lib" in
+      /*) [ -e "$dst/libs$lib" ] || { mkdir -p "$dst/libs$(dirname "$lib")"; cp -L "$lib" "$dst/libs$lib"; echo "$lib"; } ;;
+    esac
+  done
+done`
+
+// stageResolvedTools resolves the tools in toolsDir of the toolkit container into toolsStagingDir.
+// It returns the directory of the toolkit container to mount into the targets and the libraries the tools depend on.
+func stageResolvedTools(ctx context.Context, toolkitContainer, toolsDir string) (string, []string, error) {
+	out, err := execOutput(ctx, toolkitContainer, []string{"/bin/sh", "-c", resolveToolsScript, "sh", toolsDir})
+	if err != nil {
+		return "", nil, err
+	}
+	return toolsStagingDir + "/bin", strings.Fields(out), nil
+}
+
+// missingLibs returns the staged libraries the target container doesn't have, relative to /.
+func missingLibs(ctx context.Context, targetContainer string, libs []string) map[string]bool {
+	// Never overwrite the target's own files, e.g. its libc, as that could break the application being debugged
+	missing := map[string]bool{}
+	for _, lib := range libs {
+		if _, err := cli.ContainerStatPath(ctx, targetContainer, lib); err == nil {
+			continue
+		}
+		missing[strings.TrimPrefix(lib, "/")] = true
+	}
+	return missing
+}
+
+// copyMissingLibs copies the staged libraries the target container doesn't have yet into its root filesystem, so the
+// dynamically linked tools can run there. Unlike the tools mount, the libraries stay in the target until it's removed,
+// so they are only copied with --copy-libs.
+func copyMissingLibs(ctx context.Context, toolkitContainer, targetContainer string, libs []string, copyLibs bool) error {
+	missing := missingLibs(ctx, targetContainer, libs)
+	if len(missing) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(missing))
+	for lib := range missing {
+		names = append(names, "/"+lib)
+	}
+	sort.Strings(names)
+
+	if !copyLibs {
+		slog.Warn(fmt.Sprintf("%s lacks libraries some tools depend on, they may not run there, add --copy-libs to copy them into its root filesystem: %s", targetContainer, strings.Join(names, ", ")))
+		return nil
+	}
+	inspect, err := cli.ContainerInspect(ctx, targetContainer)
+	if err != nil {
+		return err
+	}
+	if inspect.HostConfig != nil && inspect.HostConfig.ReadonlyRootfs {
+		slog.Warn(fmt.Sprintf("%s has a read-only root filesystem, the libraries some tools depend on can't be copied there and they may not run: %s", targetContainer, strings.Join(names, ", ")))
+		return nil
+	}
+
+	slog.Info(fmt.Sprintf("Copying %d libraries the tools depend on into the root filesystem of %s, they stay there until it's removed", len(missing), targetContainer))
+	return copyLibsToContainer(ctx, toolkitContainer, targetContainer, missing)
+}
+
+// copyLibsToContainer copies the given libraries staged in the toolkit container to the same absolute paths in the target.
+// Only regular files are copied: directory entries are left out so that existing directories, or symlinks to them
+// such as /lib -> /usr/lib, are never replaced in the target.
+func copyLibsToContainer(ctx context.Context, toolkitContainer, targetContainer string, libs map[string]bool) error {
+	reader, _, err := cli.CopyFromContainer(ctx, toolkitContainer, toolsStagingDir+"/libs")
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(rebaseTar(reader, pw, "libs/", libs))
+	}()
+
+	return cli.CopyToContainer(ctx, targetContainer, "/", pr, types.CopyToContainerOptions{})
+}
+
+// rebaseTar copies the regular files of the tar stream in r to w with prefix stripped from their names,
+// keeping only the ones whose resulting name is in include.
+func rebaseTar(r io.Reader, w io.Writer, prefix string, include map[string]bool) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !strings.HasPrefix(hdr.Name, prefix) {
+			continue
+		}
+		hdr.Name = strings.TrimPrefix(hdr.Name, prefix)
+		if !include[hdr.Name] {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
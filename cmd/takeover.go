@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// targetTakeover is a target stopped so its copy receives its traffic, see takeOver.
+type targetTakeover struct {
+	targetContainer   string
+	copyContainerName string
+	restoreOnce       sync.Once
+	signals           chan os.Signal
+	disarmOnce        sync.Once
+	disarmed          chan struct{}
+}
+
+// takeOver stops the target container so its copy can be started on the same networks, with the same aliases and
+// published ports, and receive its traffic. restore must be deferred as soon as takeOver returns so the target is
+// restored whatever happens to the debug session.
+//
+// Until disarm is called, an interrupt also restores the target before exiting: the deferred calls don't run when the
+// process is killed by a signal, e.g. on Ctrl+C while the tools are being populated.
+func takeOver(ctx context.Context, targetContainer, copyContainerName string) (*targetTakeover, error) {
+	t := &targetTakeover{
+		targetContainer:   targetContainer,
+		copyContainerName: copyContainerName,
+		signals:           make(chan os.Signal, 1),
+		disarmed:          make(chan struct{}),
+	}
+	// Handle the signals before the target is stopped, so there is no window where it can't be restored
+	signal.Notify(t.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-t.signals:
+			slog.Warn(fmt.Sprintf("interrupted, restoring target container %s before exiting", targetContainer))
+			t.restore()
+			os.Exit(130)
+		case <-t.disarmed:
+		}
+	}()
+
+	slog.Info(fmt.Sprintf("Stopping target container %s, its traffic goes to %s until the debug session ends", targetContainer, copyContainerName))
+	if err := cli.ContainerStop(ctx, targetContainer, nil); err != nil {
+		t.disarm()
+		return nil, err
+	}
+	return t, nil
+}
+
+// disarm stops restoring the target on interrupts, for when the debug session handles them itself and returns.
+func (t *targetTakeover) disarm() {
+	t.disarmOnce.Do(func() {
+		signal.Stop(t.signals)
+		close(t.disarmed)
+	})
+}
+
+// restore stops the copy and restarts the target, once.
+func (t *targetTakeover) restore() {
+	t.restoreOnce.Do(func() {
+		// Use a fresh context, the session's may already be cancelled when it ends
+		ctx := context.Background()
+		slog.Info(fmt.Sprintf("Restoring target container %s", t.targetContainer))
+		// The copy holds the target's published ports, it must be stopped first
+		if err := cli.ContainerStop(ctx, t.copyContainerName, nil); err != nil && !client.IsErrNotFound(err) {
+			slog.Warn(fmt.Sprintf("could not stop copy container %s", t.copyContainerName), "error", err)
+		}
+		if err := cli.ContainerStart(ctx, t.targetContainer, types.ContainerStartOptions{}); err != nil {
+			slog.Warn(fmt.Sprintf("could not restart target container %s", t.targetContainer), "error", err)
+		}
+	})
+}
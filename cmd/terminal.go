@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	terminalAuto     = "auto"
+	terminalITerm    = "iterm"
+	terminalApple    = "terminal"
+	terminalInline   = "inline"
+	terminalNone     = "none"
+	osascriptBinPath = "/usr/bin/osascript"
+)
+
+// terminalLaunchers maps the values accepted by --terminal to the function that opens a new
+// terminal session running the given command.
+var terminalLaunchers = map[string]func(command string) error{
+	terminalITerm: launchITerm,
+	terminalApple: launchAppleTerminal,
+	terminalNone:  func(string) error { return nil },
+}
+
+// addTerminalFlags adds the flags controlling whether and which host terminal is opened.
+// Opening a terminal is opt-in so debug-ctr never takes unexpected UI actions, e.g. in scripts.
+func addTerminalFlags(flags *pflag.FlagSet) {
+	flags.Bool("attach", false, "(optional) Open a host terminal to shell into the container automatically")
+	flags.Bool("open-term", false, "(optional) Open a host terminal to shell into the container automatically")
+	_ = flags.MarkDeprecated("open-term", "use --attach instead")
+	flags.String("terminal", terminalAuto, "(optional) The host terminal to open when --attach is specified (auto|iterm|terminal|inline|none), inline runs the shell in the terminal debug-ctr runs in")
+}
+
+// terminalFlags returns whether a host terminal should be opened and which one.
+func terminalFlags(flags *pflag.FlagSet) (attach bool, terminal string) {
+	attach, _ = flags.GetBool("attach")
+	openTerm, _ := flags.GetBool("open-term")
+	terminal, _ = flags.GetString("terminal")
+	return attach || openTerm, terminal
+}
+
+// attachShell gives the user a shell in containerName, running args, according to terminal: in the terminal debug-ctr
+// runs in with inline, otherwise in a host terminal opened to run dockerExecCmd.
+func attachShell(ctx context.Context, terminal, dockerExecCmd, containerName string, args []string) error {
+	if terminal == terminalInline {
+		return execInteractive(ctx, containerName, args)
+	}
+	return openTerminal(terminal, dockerExecCmd)
+}
+
+// openTerminal opens a host terminal running command using the launcher selected by terminal.
+func openTerminal(terminal, command string) error {
+	if terminal == terminalAuto {
+		terminal = detectTerminal()
+	}
+	launch, ok := terminalLaunchers[terminal]
+	if !ok {
+		return fmt.Errorf("unknown terminal %q (valid values: %s|%s|%s|%s|%s)", terminal, terminalAuto, terminalITerm, terminalApple, terminalInline, terminalNone)
+	}
+	if terminal != terminalNone && runtime.GOOS != "darwin" {
+		//TODO: windows
+		//TODO: linux
+		return fmt.Errorf("terminal %q is not supported on %s", terminal, runtime.GOOS)
+	}
+	return launch(command)
+}
+
+// detectTerminal picks the terminal the user is most likely running, preferring the one
+// debug-ctr was launched from and falling back to whichever is installed.
+func detectTerminal() string {
+	if runtime.GOOS != "darwin" {
+		return terminalNone
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app":
+		return terminalITerm
+	case "Apple_Terminal":
+		return terminalApple
+	}
+	if _, err := os.Stat("/Applications/iTerm.app"); err == nil {
+		return terminalITerm
+	}
+	return terminalApple
+}
+
+func launchITerm(command string) error {
+	args := fmt.Sprintf(`
+		reopen
+        tell current window
+          create tab with default profile
+          tell current session
+            write text %s
+          end tell
+        end tell
+      end tell`, appleScriptString(command))
+
+	return exec.Command(osascriptBinPath, "-e", "tell application \"iTerm\"", "-e", args).Run()
+}
+
+func launchAppleTerminal(command string) error {
+	args := fmt.Sprintf(`
+		activate
+		do script %s
+	end tell`, appleScriptString(command))
+
+	return exec.Command(osascriptBinPath, "-e", "tell application \"Terminal\"", "-e", args).Run()
+}
+
+// appleScriptString returns s as a double-quoted AppleScript string literal.
+// Backslashes must be escaped before quotes so the escapes added for quotes are not doubled.
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell command line.
+// Words made only of characters the shell treats literally are returned unchanged.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,/:=@%+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"my-app", "my-app"},
+		{"/.debugger/sh", "/.debugger/sh"},
+		{"", "''"},
+		{"my app", "'my app'"},
+		{"it's", `'it'\''s'`},
+		{`a\b`, `'a\b'`},
+		{"$HOME", "'$HOME'"},
+		{`say "hi"`, `'say "hi"'`},
+		{"`id`", "'`id`'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestShellQuoteRoundTrip checks the shell reads back the quoted words unchanged.
+func TestShellQuoteRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to run the quoted words")
+	}
+	for _, in := range []string{"my app", "it's", `a\b`, "$HOME", `say "hi"`, "`id`", `'\''`, "a\tb"} {
+		out, err := exec.Command(sh, "-c", "printf %s "+shellQuote(in)).Output()
+		if err != nil {
+			t.Fatalf("sh -c printf %%s %s: %v", shellQuote(in), err)
+		}
+		if string(out) != in {
+			t.Errorf("sh read back %q from %s, want %q", out, shellQuote(in), in)
+		}
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{"my app", `"my app"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\b`, `"a\\b"`},
+		{`a\"b`, `"a\\\"b"`},
+		{"$PATH", `"$PATH"`},
+		{"it's", `"it's"`},
+	}
+	for _, tt := range tests {
+		if got := appleScriptString(tt.in); got != tt.want {
+			t.Errorf("appleScriptString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestExecCommandQuoting checks the docker exec commands of the add-mount and copy modes with special characters in
+// the container's name, as printed for the shell and as embedded in the AppleScript opening a terminal.
+func TestExecCommandQuoting(t *testing.T) {
+	copyCommand := func(name string) string {
+		return copyExecCommand(name, debuggerMountPath, debuggerMountPath+"/sh")
+	}
+	tests := []struct {
+		mode        string
+		command     func(name string) string
+		name        string
+		shell       string
+		appleScript string
+	}{
+		{
+			mode:        "add-mount",
+			command:     addMountExecCommand,
+			name:        "my-distroless",
+			shell:       `docker exec -it my-distroless /bin/sh`,
+			appleScript: `"docker exec -it my-distroless /bin/sh"`,
+		},
+		{
+			mode:        "add-mount",
+			command:     addMountExecCommand,
+			name:        "it's $my app",
+			shell:       `docker exec -it 'it'\''s $my app' /bin/sh`,
+			appleScript: `"docker exec -it 'it'\\''s $my app' /bin/sh"`,
+		},
+		{
+			mode:        "add-mount",
+			command:     addMountExecCommand,
+			name:        `back\slash"quote`,
+			shell:       `docker exec -it 'back\slash"quote' /bin/sh`,
+			appleScript: `"docker exec -it 'back\\slash\"quote' /bin/sh"`,
+		},
+		{
+			mode:        "copy",
+			command:     copyCommand,
+			name:        "my-copy",
+			shell:       `docker exec -it my-copy /.debugger/sh -c "PATH=\$PATH:/.debugger /.debugger/sh"`,
+			appleScript: `"docker exec -it my-copy /.debugger/sh -c \"PATH=\\$PATH:/.debugger /.debugger/sh\""`,
+		},
+		{
+			mode:        "copy",
+			command:     copyCommand,
+			name:        "it's $my copy",
+			shell:       `docker exec -it 'it'\''s $my copy' /.debugger/sh -c "PATH=\$PATH:/.debugger /.debugger/sh"`,
+			appleScript: `"docker exec -it 'it'\\''s $my copy' /.debugger/sh -c \"PATH=\\$PATH:/.debugger /.debugger/sh\""`,
+		},
+		{
+			mode:        "copy",
+			command:     copyCommand,
+			name:        `back\slash"quote`,
+			shell:       `docker exec -it 'back\slash"quote' /.debugger/sh -c "PATH=\$PATH:/.debugger /.debugger/sh"`,
+			appleScript: `"docker exec -it 'back\\slash\"quote' /.debugger/sh -c \"PATH=\\$PATH:/.debugger /.debugger/sh\""`,
+		},
+	}
+	for _, tt := range tests {
+		shell := tt.command(tt.name)
+		if shell != tt.shell {
+			t.Errorf("%s: the exec command of %q is %s, want %s", tt.mode, tt.name, shell, tt.shell)
+		}
+		if got := appleScriptString(shell); got != tt.appleScript {
+			t.Errorf("%s: the AppleScript string of the exec command of %q is %s, want %s", tt.mode, tt.name, got, tt.appleScript)
+		}
+	}
+}
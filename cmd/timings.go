@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// phaseTiming is the duration of a phase of the debug session, recorded with --timings.
+type phaseTiming struct {
+	phase    string
+	duration time.Duration
+}
+
+var (
+	timingsEnabled bool
+	timingsMu      sync.Mutex
+	timings        []phaseTiming
+)
+
+// recordPhase records that phase took the time since start if --timings is set. Phases can be recorded concurrently,
+// e.g. while pulling images.
+func recordPhase(phase string, start time.Time) {
+	if !timingsEnabled {
+		return
+	}
+	d := time.Since(start)
+
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+	timings = append(timings, phaseTiming{phase: phase, duration: d})
+}
+
+// printTimings writes the phases recorded so far to stderr as a table, in the order they ended.
+func printTimings() {
+	if !timingsEnabled {
+		return
+	}
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tDURATION")
+	for _, t := range timings {
+		fmt.Fprintf(w, "%s\t%s\n", t.phase, t.duration.Round(time.Millisecond))
+	}
+	_ = w.Flush()
+}
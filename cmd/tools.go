@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// listToolsScript prints the name, path and, for the busybox applets, version of the executables on the PATH of the
+// image, tab-separated. Only shell builtins are used besides busybox itself, no tool is run to ask its version as
+// some would do their job instead.
+const listToolsScript = `bb=$(command -v busybox 2>/dev/null)
+bbver=
+if [ -n "$bb" ]; then
+  bbver=$("$bb" 2>&1 | { read -r line; echo "$line"; })
+  bbver=${bbver%% (*}
+fi
+IFS=:
+for d in $PATH; do
+  [ -d "$d" ] || continue
+  for p in "$d"/*; do
+    [ -f "$p" ] && [ -x "$p" ] || continue
+    v=
+    if [ -n "$bb" ] && [ "$p" -ef "$bb" ]; then v=$bbver; fi
+    printf '%s\t%s\t%s\n' "${p##*/}" "$p" "$v"
+  done
+done`
+
+// tool is an executable on the PATH of a debug image.
+type tool struct {
+	name    string
+	path    string
+	version string
+}
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "List the tools available in a debug image",
+	Long: `Runs a container of the debug image and lists the executables on its PATH, with their version when it's
+cheap to know, e.g. for the busybox applets, so you can check an image has the tools you need before debugging.
+The image is pulled if it's not available locally, it must include /bin/sh.`,
+	Example: `
+debug-ctr tools
+debug-ctr tools --image=nicolaka/netshoot --grep=dig
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		image, _ := cmd.Flags().GetString("image")
+		grep, _ := cmd.Flags().GetString("grep")
+
+		ctx := context.Background()
+		if _, _, err := cli.ImageInspectWithRaw(ctx, image); err != nil {
+			if err := pullImage(ctx, image); err != nil {
+				return withExitCode(exitCodePullFailed, err)
+			}
+		}
+		tools, err := listTools(ctx, image)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH\tVERSION")
+		for _, t := range tools {
+			if grep != "" && !strings.Contains(t.name, grep) {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.name, t.path, t.version)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(toolsCmd)
+
+	toolsCmd.Flags().String("image", "docker.io/library/busybox:latest", "(optional) The debug image whose tools to list")
+	toolsCmd.Flags().String("grep", "", "(optional) Only list the tools whose name contains this string")
+}
+
+// listTools returns the executables on the PATH of image, sorted by name. When several directories of the PATH have
+// an executable with the same name, only the one found first is returned, as it's the one that runs.
+func listTools(ctx context.Context, image string) ([]tool, error) {
+	out, err := runHelper(ctx, image, "", false, listToolsScript)
+	if err != nil {
+		return nil, fmt.Errorf("listing the tools of %s: %w", image, err)
+	}
+
+	seen := map[string]bool{}
+	var tools []tool
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		tools = append(tools, tool{name: fields[0], path: fields[1], version: fields[2]})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].name < tools[j].name })
+	return tools, nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Build information, injected at build time via -ldflags "-X github.com/felipecruz91/debug-ctr/cmd.version=...".
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of debug-ctr and of the Docker daemon",
+	Long:  `Prints the build information of debug-ctr together with the Docker server version and the negotiated API version, useful when reporting issues.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+
+		fmt.Fprintln(out, "Client:")
+		fmt.Fprintf(out, " Version:\t%s\n", buildVersion())
+		if commit != "" {
+			fmt.Fprintf(out, " Git commit:\t%s\n", commit)
+		}
+		if date != "" {
+			fmt.Fprintf(out, " Built:\t\t%s\n", date)
+		}
+		fmt.Fprintf(out, " Go version:\t%s\n", runtime.Version())
+		fmt.Fprintf(out, " OS/Arch:\t%s/%s\n", runtime.GOOS, runtime.GOARCH)
+
+		server, err := cli.ServerVersion(context.Background())
+		if err != nil {
+			fmt.Fprintf(out, " API version:\t%s\n", cli.ClientVersion())
+			return err
+		}
+		// The client negotiated the API version with the daemon on the first request
+		fmt.Fprintf(out, " API version:\t%s (negotiated)\n", cli.ClientVersion())
+
+		fmt.Fprintln(out, "Server:")
+		fmt.Fprintf(out, " Version:\t%s\n", server.Version)
+		fmt.Fprintf(out, " API version:\t%s (minimum version %s)\n", server.APIVersion, server.MinAPIVersion)
+		fmt.Fprintf(out, " OS/Arch:\t%s/%s\n", server.Os, server.Arch)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+// buildVersion returns the version injected at build time, falling back to the module version for `go install` builds.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/spf13/cobra"
+)
+
+var watchFilterFlag []string
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Create a copy of the containers that crash, as they crash",
+	Long: `Watches the Docker events and, whenever a container matching the filters dies with a non-zero exit code, creates a
+copy of it that is kept running with the tools of --image, so transient crashes can be investigated afterwards.
+The docker exec command of every copy is printed and, with --log-file, appended to a file.`,
+	Example: `
+debug-ctr watch
+debug-ctr watch --filter label=com.docker.compose.project=myproj --max-concurrent=2
+debug-ctr watch --filter name=my-flaky-service --log-file=crashes.log
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debugImage, _ := cmd.Flags().GetString("image")
+		maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent")
+		logFile, _ := cmd.Flags().GetString("log-file")
+		if maxConcurrent < 1 {
+			return fmt.Errorf("invalid --max-concurrent %d: expected at least 1", maxConcurrent)
+		}
+
+		eventFilters := filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("event", "die"),
+		)
+		for _, f := range watchFilterFlag {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok || (key != "label" && key != "name") {
+				return fmt.Errorf("invalid --filter %q: expected label=<key>[=<value>] or name=<name>", f)
+			}
+			eventFilters.Add(key, value)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := pullImage(ctx, debugImage); err != nil {
+			return withExitCode(exitCodePullFailed, err)
+		}
+
+		w := &crashWatcher{
+			debugImage: debugImage,
+			logFile:    logFile,
+			slots:      make(chan struct{}, maxConcurrent),
+		}
+		return w.run(ctx, eventFilters)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().String("image", "docker.io/library/busybox:latest", "(optional) The image whose tools are added to the copies, it must include sh and sleep")
+	watchCmd.Flags().StringArrayVar(&watchFilterFlag, "filter", nil, "(optional) Only watch the containers matching label=<key>[=<value>] or name=<name>, can be repeated")
+	watchCmd.Flags().Int("max-concurrent", 1, "(optional) How many copies can be created at the same time, the crashes happening meanwhile are skipped")
+	watchCmd.Flags().String("log-file", "", "(optional) A file to append the docker exec command of every copy to")
+}
+
+// crashWatcher creates a copy of the containers that die with a non-zero exit code.
+type crashWatcher struct {
+	debugImage string
+	logFile    string
+	// slots bounds the number of copies created at the same time.
+	slots chan struct{}
+	wg    sync.WaitGroup
+	// logMu serializes the writes to logFile.
+	logMu sync.Mutex
+}
+
+// run handles the events matching args until ctx is done, then waits for the copies being created.
+func (w *crashWatcher) run(ctx context.Context, args filters.Args) error {
+	defer w.wg.Wait()
+
+	slog.Info("Watching for crashing containers, press Ctrl+C to stop")
+	msgs, errs := cli.Events(ctx, types.EventsOptions{Filters: args})
+	for {
+		select {
+		case msg := <-msgs:
+			w.handle(ctx, msg)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// handle starts creating a copy of the container that died in msg if it crashed, unless it's a copy itself or too
+// many copies are already being created.
+func (w *crashWatcher) handle(ctx context.Context, msg events.Message) {
+	exitCode := msg.Actor.Attributes["exitCode"]
+	name := msg.Actor.Attributes["name"]
+	if exitCode == "0" || exitCode == "" {
+		return
+	}
+	if _, ok := msg.Actor.Attributes[labelTarget]; ok {
+		// Never copy the copies
+		return
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+	default:
+		slog.Warn(fmt.Sprintf("%s exited with status %s, skipped as %d copies are already being created", name, exitCode, cap(w.slots)))
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() { <-w.slots }()
+
+		slog.Info(fmt.Sprintf("%s exited with status %s, creating a copy", name, exitCode))
+		if err := w.capture(ctx, name); err != nil {
+			slog.Warn(fmt.Sprintf("could not create a copy of %s", name), "error", err)
+		}
+	}()
+}
+
+// capture creates a copy of the target kept running, and records the command to debug it.
+func (w *crashWatcher) capture(ctx context.Context, target string) error {
+	copyContainerName := fmt.Sprintf("%s-debug-%d", target, time.Now().Unix())
+	if err := createCopyContainer(ctx, copyOptions{
+		debugImages:        []string{w.debugImage},
+		targetContainer:    target,
+		copyContainerName:  copyContainerName,
+		entrypointOverride: keepAliveArgs[:1],
+		cmdOverride:        keepAliveArgs[1:],
+		stripLabels:        defaultStripLabels,
+		entrypointProbe:    true,
+	}); err != nil {
+		return err
+	}
+
+	dockerExecCmd := copyExecCommand(copyContainerName, debuggerMountPath, debuggerMountPath+"/sh")
+	emitEvent(event{Type: eventExecReady, Container: copyContainerName, Command: dockerExecCmd})
+	printDebugCommand(dockerExecCmd)
+	if w.logFile == "" {
+		return nil
+	}
+
+	w.logMu.Lock()
+	defer w.logMu.Unlock()
+	f, err := os.OpenFile(w.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), target, dockerExecCmd); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}